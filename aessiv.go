@@ -0,0 +1,174 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides deterministic authenticated encryption using AES-SIV as described
+// in RFC 5297.
+//
+// Deterministic encryption always produces the same ciphertext for the same
+// key, plaintext and additional data. That makes it possible to look up
+// encrypted values by equality, e.g. finding a user by encrypted email
+// address, but it also means anyone who can see the ciphertexts can tell which
+// records hold equal values. It reveals nothing else. Only use it for fields
+// you need to search on; everything else should use Encrypt.
+package cryptopasta
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// EncryptDeterministic encrypts data using AES-SIV with a 256-bit key (two
+// AES-128 keys). Output takes the form iv|ciphertext where the 16-byte
+// synthetic IV also serves as the authentication tag. The additional data is
+// authenticated but not encrypted, and must match on decryption; use it to
+// scope lookups, e.g. by table and column name.
+func EncryptDeterministic(plaintext, additionalData []byte, key *[32]byte) ([]byte, error) {
+	return aesSIVSeal(key, plaintext, additionalData)
+}
+
+// DecryptDeterministic decrypts data produced by EncryptDeterministic.
+func DecryptDeterministic(ciphertext, additionalData []byte, key *[32]byte) ([]byte, error) {
+	return aesSIVOpen(key, ciphertext, additionalData)
+}
+
+// aesSIVSeal implements SIV-ENCRYPT from RFC 5297 section 2.6, with the
+// plaintext as the last S2V input.
+func aesSIVSeal(key *[32]byte, plaintext []byte, additionalData ...[]byte) ([]byte, error) {
+	macBlock, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, err
+	}
+	ctrBlock, err := aes.NewCipher(key[16:])
+	if err != nil {
+		return nil, err
+	}
+
+	v := s2v(macBlock, append(append([][]byte{}, additionalData...), plaintext))
+
+	out := make([]byte, aes.BlockSize+len(plaintext))
+	copy(out, v[:])
+	sivCTR(ctrBlock, v, out[aes.BlockSize:], plaintext)
+	return out, nil
+}
+
+// aesSIVOpen implements SIV-DECRYPT from RFC 5297 section 2.7.
+func aesSIVOpen(key *[32]byte, ciphertext []byte, additionalData ...[]byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize {
+		return nil, errors.New("malformed ciphertext")
+	}
+
+	macBlock, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, err
+	}
+	ctrBlock, err := aes.NewCipher(key[16:])
+	if err != nil {
+		return nil, err
+	}
+
+	var v [aes.BlockSize]byte
+	copy(v[:], ciphertext)
+
+	plaintext := make([]byte, len(ciphertext)-aes.BlockSize)
+	sivCTR(ctrBlock, v, plaintext, ciphertext[aes.BlockSize:])
+
+	t := s2v(macBlock, append(append([][]byte{}, additionalData...), plaintext))
+	if subtle.ConstantTimeCompare(t[:], v[:]) != 1 {
+		return nil, errors.New("aessiv: message authentication failed")
+	}
+
+	return plaintext, nil
+}
+
+// sivCTR runs AES-CTR from the IV with bits 31 and 63 cleared, as RFC 5297
+// section 2.5 requires.
+func sivCTR(block cipher.Block, v [aes.BlockSize]byte, out, in []byte) {
+	q := v
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+	cipher.NewCTR(block, q[:]).XORKeyStream(out, in)
+}
+
+// s2v implements the S2V pseudo-random function from RFC 5297 section 2.4.
+// The last input must be the plaintext.
+func s2v(block cipher.Block, inputs [][]byte) [aes.BlockSize]byte {
+	var zero [aes.BlockSize]byte
+	d := cmac(block, zero[:])
+
+	for _, s := range inputs[:len(inputs)-1] {
+		d = dbl(d)
+		mac := cmac(block, s)
+		subtle.XORBytes(d[:], d[:], mac[:])
+	}
+
+	last := inputs[len(inputs)-1]
+	var t []byte
+	if len(last) >= aes.BlockSize {
+		t = append([]byte{}, last...)
+		end := t[len(t)-aes.BlockSize:]
+		subtle.XORBytes(end, end, d[:])
+	} else {
+		d = dbl(d)
+		t = make([]byte, aes.BlockSize)
+		copy(t, last)
+		t[len(last)] = 0x80
+		subtle.XORBytes(t, t, d[:])
+	}
+
+	return cmac(block, t)
+}
+
+// cmac computes AES-CMAC as described in RFC 4493.
+func cmac(block cipher.Block, msg []byte) [aes.BlockSize]byte {
+	var l [aes.BlockSize]byte
+	block.Encrypt(l[:], l[:])
+	k1 := dbl(l)
+	k2 := dbl(k1)
+
+	// The last block is treated specially: if complete it's XORed with K1,
+	// otherwise it's padded with 10* and XORed with K2.
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	if n == 0 {
+		n = 1
+	}
+	lastStart := (n - 1) * aes.BlockSize
+
+	var last [aes.BlockSize]byte
+	if len(msg)-lastStart == aes.BlockSize {
+		subtle.XORBytes(last[:], msg[lastStart:], k1[:])
+	} else {
+		copy(last[:], msg[lastStart:])
+		last[len(msg)-lastStart] = 0x80
+		subtle.XORBytes(last[:], last[:], k2[:])
+	}
+
+	var x [aes.BlockSize]byte
+	for i := 0; i < lastStart; i += aes.BlockSize {
+		subtle.XORBytes(x[:], x[:], msg[i:i+aes.BlockSize])
+		block.Encrypt(x[:], x[:])
+	}
+	subtle.XORBytes(x[:], x[:], last[:])
+	block.Encrypt(x[:], x[:])
+	return x
+}
+
+// dbl multiplies by x in GF(2^128) with the polynomial x^128 + x^7 + x^2 + x + 1.
+func dbl(in [aes.BlockSize]byte) [aes.BlockSize]byte {
+	var out [aes.BlockSize]byte
+	carry := in[0] >> 7
+	for i := 0; i < aes.BlockSize-1; i++ {
+		out[i] = in[i]<<1 | in[i+1]>>7
+	}
+	out[aes.BlockSize-1] = in[aes.BlockSize-1]<<1 ^ (0x87 & -carry)
+	return out
+}
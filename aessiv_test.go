@@ -0,0 +1,159 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+// https://tools.ietf.org/html/rfc4493#section-4
+var cmacTests = []struct {
+	key string
+	msg string
+	mac string
+}{
+	{
+		key: "2b7e151628aed2a6abf7158809cf4f3c",
+		msg: "",
+		mac: "bb1d6929e95937287fa37d129b756746",
+	},
+	{
+		key: "2b7e151628aed2a6abf7158809cf4f3c",
+		msg: "6bc1bee22e409f96e93d7e117393172a",
+		mac: "070a16b46b4d4144f79bdd9dd04a287c",
+	},
+}
+
+func TestCMAC(t *testing.T) {
+	for idx, tt := range cmacTests {
+		key, _ := hex.DecodeString(tt.key)
+		msg, _ := hex.DecodeString(tt.msg)
+		expected, _ := hex.DecodeString(tt.mac)
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mac := cmac(block, msg)
+		if !bytes.Equal(mac[:], expected) {
+			t.Errorf("test %d: got %x, expected %x", idx, mac, expected)
+		}
+	}
+}
+
+// https://tools.ietf.org/html/rfc5297#appendix-A
+var sivTests = []struct {
+	key        string
+	ad         []string
+	plaintext  string
+	ciphertext string
+}{
+	{
+		// A.1 Deterministic Authenticated Encryption Example
+		key:        "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		ad:         []string{"101112131415161718191a1b1c1d1e1f2021222324252627"},
+		plaintext:  "112233445566778899aabbccddee",
+		ciphertext: "85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c",
+	},
+	{
+		// A.2 Nonce-Based Authenticated Encryption Example
+		key: "7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f",
+		ad: []string{
+			"00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100",
+			"102030405060708090a0",
+			"09f911029d74e35bd84156c5635688c0",
+		},
+		plaintext:  "7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553",
+		ciphertext: "7bdb6e3b432667eb06f4d14bff2fbd0fcb900f2fddbe404326601965c889bf17dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d",
+	},
+}
+
+func TestAESSIVVectors(t *testing.T) {
+	for idx, tt := range sivTests {
+		keySlice, _ := hex.DecodeString(tt.key)
+		plaintext, _ := hex.DecodeString(tt.plaintext)
+		expected, _ := hex.DecodeString(tt.ciphertext)
+
+		key := &[32]byte{}
+		copy(key[:], keySlice)
+
+		var ad [][]byte
+		for _, s := range tt.ad {
+			b, _ := hex.DecodeString(s)
+			ad = append(ad, b)
+		}
+
+		ciphertext, err := aesSIVSeal(key, plaintext, ad...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ciphertext, expected) {
+			t.Errorf("test %d: got %x, expected %x", idx, ciphertext, expected)
+		}
+
+		decrypted, err := aesSIVOpen(key, expected, ad...)
+		if err != nil {
+			t.Errorf("test %d: %v", idx, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("test %d: plaintexts don't match", idx)
+		}
+	}
+}
+
+func TestEncryptDeterministic(t *testing.T) {
+	key := NewEncryptionKey()
+	email := []byte("gopher@example.com")
+	column := []byte("users.email")
+
+	a, err := EncryptDeterministic(email, column, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := EncryptDeterministic(email, column, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(a, b) {
+		t.Error("equal plaintexts produced different ciphertexts")
+	}
+
+	c, err := EncryptDeterministic(email, []byte("users.backup_email"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, c) {
+		t.Error("different additional data produced the same ciphertext")
+	}
+
+	plaintext, err := DecryptDeterministic(a, column, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, email) {
+		t.Errorf("plaintexts don't match")
+	}
+
+	if _, err := DecryptDeterministic(a, []byte("users.backup_email"), key); err == nil {
+		t.Error("decrypted with the wrong additional data")
+	}
+
+	a[len(a)-1] ^= 0xff
+	if _, err := DecryptDeterministic(a, column, key); err == nil {
+		t.Error("decrypted an altered ciphertext")
+	}
+}
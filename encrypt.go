@@ -10,6 +10,10 @@
 // with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
 
 // Provides symmetric authenticated encryption using 256-bit AES-GCM with a random nonce.
+//
+// XChaCha20-Poly1305 is also available for high-volume uses. Its 192-bit
+// random nonces can be generated without any practical risk of collision, so
+// it does not share AES-GCM's limit of roughly 2^32 messages per key.
 package cryptopasta
 
 import (
@@ -18,6 +22,8 @@ import (
 	"crypto/rand"
 	"errors"
 	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
 )
 
 // NewEncryptionKey generates a random 256-bit key for Encrypt() and
@@ -35,6 +41,21 @@ func NewEncryptionKey() *[32]byte {
 // the data and provides a check that it hasn't been altered. Output takes the
 // form nonce|ciphertext|tag where '|' indicates concatenation.
 func Encrypt(plaintext []byte, key *[32]byte) (ciphertext []byte, err error) {
+	return EncryptWithAD(plaintext, nil, key)
+}
+
+// Decrypt decrypts data using 256-bit AES-GCM.  This both hides the content of
+// the data and provides a check that it hasn't been altered. Expects input
+// form nonce|ciphertext|tag where '|' indicates concatenation.
+func Decrypt(ciphertext []byte, key *[32]byte) (plaintext []byte, err error) {
+	return DecryptWithAD(ciphertext, nil, key)
+}
+
+// EncryptWithAD is like Encrypt, but also authenticates additionalData without
+// including it in the output. Use it to bind a ciphertext to its context, such
+// as the database row or tenant it belongs to: DecryptWithAD fails unless it is
+// given the same additional data.
+func EncryptWithAD(plaintext, additionalData []byte, key *[32]byte) (ciphertext []byte, err error) {
 	block, err := aes.NewCipher(key[:])
 	if err != nil {
 		return nil, err
@@ -51,13 +72,12 @@ func Encrypt(plaintext []byte, key *[32]byte) (ciphertext []byte, err error) {
 		return nil, err
 	}
 
-	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
 }
 
-// Decrypt decrypts data using 256-bit AES-GCM.  This both hides the content of
-// the data and provides a check that it hasn't been altered. Expects input
-// form nonce|ciphertext|tag where '|' indicates concatenation.
-func Decrypt(ciphertext []byte, key *[32]byte) (plaintext []byte, err error) {
+// DecryptWithAD decrypts data produced by EncryptWithAD. It fails if
+// additionalData differs from what was supplied at encryption time.
+func DecryptWithAD(ciphertext, additionalData []byte, key *[32]byte) (plaintext []byte, err error) {
 	block, err := aes.NewCipher(key[:])
 	if err != nil {
 		return nil, err
@@ -75,6 +95,53 @@ func Decrypt(ciphertext []byte, key *[32]byte) (plaintext []byte, err error) {
 	return gcm.Open(nil,
 		ciphertext[:gcm.NonceSize()],
 		ciphertext[gcm.NonceSize():],
-		nil,
+		additionalData,
+	)
+}
+
+// EncryptXChaCha encrypts data using XChaCha20-Poly1305 with a random 192-bit
+// nonce. Like Encrypt, it both hides the content of the data and provides a
+// check that it hasn't been altered, and output takes the form
+// nonce|ciphertext|tag. Prefer it over Encrypt when a single key will encrypt
+// billions of messages.
+func EncryptXChaCha(plaintext []byte, key *[32]byte) (ciphertext []byte, err error) {
+	return encryptXChaCha(plaintext, nil, key)
+}
+
+// DecryptXChaCha decrypts data using XChaCha20-Poly1305. Expects input form
+// nonce|ciphertext|tag where '|' indicates concatenation.
+func DecryptXChaCha(ciphertext []byte, key *[32]byte) (plaintext []byte, err error) {
+	return decryptXChaCha(ciphertext, nil, key)
+}
+
+func encryptXChaCha(plaintext, additionalData []byte, key *[32]byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func decryptXChaCha(ciphertext, additionalData []byte, key *[32]byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+
+	return aead.Open(nil,
+		ciphertext[:aead.NonceSize()],
+		ciphertext[aead.NonceSize():],
+		additionalData,
 	)
 }
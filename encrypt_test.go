@@ -61,6 +61,89 @@ func TestEncryptDecryptGCM(t *testing.T) {
 	}
 }
 
+func TestEncryptDecryptWithAD(t *testing.T) {
+	key := NewEncryptionKey()
+	plaintext := []byte("4111 1111 1111 1111")
+
+	ciphertext, err := EncryptWithAD(plaintext, []byte("customers/1234"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := DecryptWithAD(ciphertext, []byte("customers/1234"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("plaintexts don't match")
+	}
+
+	// A ciphertext copied into another record must not decrypt there.
+	substitutions := [][]byte{
+		[]byte("customers/1235"),
+		[]byte("customers/123"),
+		nil,
+	}
+
+	for _, ad := range substitutions {
+		_, err = DecryptWithAD(ciphertext, ad, key)
+		if err == nil {
+			t.Errorf("decrypted with additional data %q", ad)
+		}
+	}
+
+	_, err = Decrypt(ciphertext, key)
+	if err == nil {
+		t.Errorf("decrypted without additional data")
+	}
+
+	// Encrypt is EncryptWithAD with no additional data.
+	ciphertext, err = Encrypt(plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = DecryptWithAD(ciphertext, nil, key)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEncryptDecryptXChaCha(t *testing.T) {
+	key := NewEncryptionKey()
+	message := []byte("Hello, world!")
+
+	ciphertext, err := EncryptXChaCha(message, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ciphertext) != 24+len(message)+16 {
+		t.Errorf("unexpected ciphertext length %d", len(ciphertext))
+	}
+
+	plaintext, err := DecryptXChaCha(ciphertext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(plaintext, message) {
+		t.Errorf("plaintexts don't match")
+	}
+
+	ciphertext[0] ^= 0xff
+	_, err = DecryptXChaCha(ciphertext, key)
+	if err == nil {
+		t.Errorf("xchacha20poly1305 open should not have worked, but did")
+	}
+
+	_, err = DecryptXChaCha(ciphertext[:10], key)
+	if err == nil {
+		t.Errorf("decrypted a truncated ciphertext")
+	}
+}
+
 func BenchmarkAESGCM(b *testing.B) {
 	randomKey := &[32]byte{}
 	_, err := io.ReadFull(rand.Reader, randomKey[:])
@@ -102,3 +185,21 @@ func BenchmarkSecretbox(b *testing.B) {
 		secretbox.Seal(nil, data, nonce, randomKey)
 	}
 }
+
+func BenchmarkXChaCha20Poly1305(b *testing.B) {
+	randomKey := &[32]byte{}
+	_, err := io.ReadFull(rand.Reader, randomKey[:])
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile("testdata/big")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		EncryptXChaCha(data, randomKey)
+	}
+}
@@ -0,0 +1,131 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides envelope encryption with a pluggable key-encryption key.
+//
+// Each message is encrypted with Encrypt under a fresh data key, and the data
+// key is in turn encrypted ("wrapped") by a key-encryption key that never
+// leaves its home, such as a KMS or HSM. Output takes the form
+// length|wrapped key|nonce|ciphertext|tag, where length is the size of the
+// wrapped key as a big-endian uint16. The wrapped key is authenticated as
+// additional data, so it can't be swapped for another.
+package cryptopasta
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// KeyWrapper protects data keys with a key-encryption key that it holds.
+type KeyWrapper interface {
+	// Wrap encrypts a data key.
+	Wrap(key *[32]byte) ([]byte, error)
+	// Unwrap decrypts a data key produced by Wrap.
+	Unwrap(wrapped []byte) (*[32]byte, error)
+}
+
+// NewLocalKeyWrapper returns a KeyWrapper that wraps data keys with Encrypt
+// under kek, which can come from NewEncryptionKey.
+func NewLocalKeyWrapper(kek *[32]byte) KeyWrapper {
+	return &localKeyWrapper{kek: kek}
+}
+
+type localKeyWrapper struct {
+	kek *[32]byte
+}
+
+var dataKeyAD = []byte("cryptopasta wrapped data key")
+
+func (lw *localKeyWrapper) Wrap(key *[32]byte) ([]byte, error) {
+	return EncryptWithAD(key[:], dataKeyAD, lw.kek)
+}
+
+func (lw *localKeyWrapper) Unwrap(wrapped []byte) (*[32]byte, error) {
+	plaintext, err := DecryptWithAD(wrapped, dataKeyAD, lw.kek)
+	if err != nil {
+		return nil, err
+	}
+	return dataKeyFromBytes(plaintext)
+}
+
+// KeyWrapperFuncs adapts a pair of functions, typically calls to an external
+// KMS client's encrypt and decrypt operations, to the KeyWrapper interface.
+type KeyWrapperFuncs struct {
+	WrapFunc   func(key []byte) ([]byte, error)
+	UnwrapFunc func(wrapped []byte) ([]byte, error)
+}
+
+// Wrap calls WrapFunc.
+func (f KeyWrapperFuncs) Wrap(key *[32]byte) ([]byte, error) {
+	return f.WrapFunc(key[:])
+}
+
+// Unwrap calls UnwrapFunc and checks that it returned a 256-bit key.
+func (f KeyWrapperFuncs) Unwrap(wrapped []byte) (*[32]byte, error) {
+	plaintext, err := f.UnwrapFunc(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return dataKeyFromBytes(plaintext)
+}
+
+func dataKeyFromBytes(b []byte) (*[32]byte, error) {
+	if len(b) != 32 {
+		return nil, errors.New("unwrapped data key has wrong length")
+	}
+	key := &[32]byte{}
+	copy(key[:], b)
+	return key, nil
+}
+
+// EnvelopeEncrypt encrypts data under a new random data key and stores the
+// data key, wrapped by kek, alongside the ciphertext.
+func EnvelopeEncrypt(plaintext []byte, kek KeyWrapper) ([]byte, error) {
+	dataKey := NewEncryptionKey()
+
+	wrapped, err := kek.Wrap(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) > 0xffff {
+		return nil, errors.New("wrapped data key too long")
+	}
+
+	ciphertext, err := EncryptWithAD(plaintext, wrapped, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 2, 2+len(wrapped)+len(ciphertext))
+	binary.BigEndian.PutUint16(out, uint16(len(wrapped)))
+	out = append(out, wrapped...)
+	return append(out, ciphertext...), nil
+}
+
+// EnvelopeDecrypt unwraps the data key stored with data produced by
+// EnvelopeEncrypt and uses it to decrypt the data.
+func EnvelopeDecrypt(ciphertext []byte, kek KeyWrapper) ([]byte, error) {
+	if len(ciphertext) < 2 {
+		return nil, errors.New("malformed ciphertext")
+	}
+	wrappedLen := int(binary.BigEndian.Uint16(ciphertext))
+	if len(ciphertext) < 2+wrappedLen {
+		return nil, errors.New("malformed ciphertext")
+	}
+	wrapped := ciphertext[2 : 2+wrappedLen]
+
+	dataKey, err := kek.Unwrap(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecryptWithAD(ciphertext[2+wrappedLen:], wrapped, dataKey)
+}
@@ -0,0 +1,112 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// fakeKMS stands in for a remote key management service. Like many real ones,
+// it returns an opaque handle instead of a ciphertext and keeps the key
+// material to itself.
+type fakeKMS struct {
+	keys map[uint64][]byte
+	next uint64
+}
+
+func (kms *fakeKMS) Encrypt(plaintext []byte) ([]byte, error) {
+	kms.next++
+	kms.keys[kms.next] = append([]byte{}, plaintext...)
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, kms.next)
+	return handle, nil
+}
+
+func (kms *fakeKMS) Decrypt(handle []byte) ([]byte, error) {
+	if len(handle) != 8 {
+		return nil, errors.New("kms: bad handle")
+	}
+	key, ok := kms.keys[binary.BigEndian.Uint64(handle)]
+	if !ok {
+		return nil, errors.New("kms: not found")
+	}
+	return key, nil
+}
+
+func TestEnvelopeEncryption(t *testing.T) {
+	kms := &fakeKMS{keys: make(map[uint64][]byte)}
+
+	wrappers := []struct {
+		name string
+		kek  KeyWrapper
+	}{
+		{"local", NewLocalKeyWrapper(NewEncryptionKey())},
+		{"kms", KeyWrapperFuncs{WrapFunc: kms.Encrypt, UnwrapFunc: kms.Decrypt}},
+	}
+
+	message := []byte("Hello, world!")
+
+	for _, tt := range wrappers {
+		ciphertext, err := EnvelopeEncrypt(message, tt.kek)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		plaintext, err := EnvelopeDecrypt(ciphertext, tt.kek)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if !bytes.Equal(plaintext, message) {
+			t.Errorf("%s: plaintexts don't match", tt.name)
+		}
+
+		// Each message gets its own data key.
+		other, err := EnvelopeEncrypt(message, tt.kek)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		wrappedLen := 2 + int(binary.BigEndian.Uint16(ciphertext))
+		if bytes.Equal(ciphertext[:wrappedLen], other[:wrappedLen]) {
+			t.Errorf("%s: data key was reused", tt.name)
+		}
+
+		// Swapping in another message's wrapped key must fail.
+		swapped := append(append([]byte{}, other[:wrappedLen]...), ciphertext[wrappedLen:]...)
+		if _, err := EnvelopeDecrypt(swapped, tt.kek); err == nil {
+			t.Errorf("%s: decrypted with a swapped data key", tt.name)
+		}
+
+		ciphertext[len(ciphertext)-1] ^= 0xff
+		if _, err := EnvelopeDecrypt(ciphertext, tt.kek); err == nil {
+			t.Errorf("%s: decrypted an altered ciphertext", tt.name)
+		}
+	}
+}
+
+func TestEnvelopeWrongKEK(t *testing.T) {
+	ciphertext, err := EnvelopeEncrypt([]byte("Hello, world!"), NewLocalKeyWrapper(NewEncryptionKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := EnvelopeDecrypt(ciphertext, NewLocalKeyWrapper(NewEncryptionKey())); err == nil {
+		t.Error("decrypted with the wrong key-encryption key")
+	}
+
+	if _, err := EnvelopeDecrypt(ciphertext[:1], NewLocalKeyWrapper(NewEncryptionKey())); err == nil {
+		t.Error("decrypted a truncated ciphertext")
+	}
+}
@@ -0,0 +1,147 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides whole-file encryption.
+//
+// Files are encrypted with the streaming format from NewEncryptWriter, so they
+// never have to fit in memory. Output is written to a temporary file in the
+// destination directory, readable only by its owner, and moved into place
+// only once it is complete. A decrypted file therefore never appears unless
+// every segment authenticated. The directory is synced after the move, so the
+// new file survives a crash once EncryptFile or DecryptFile returns.
+//
+// Without overwrite, the move is a hard link. Filesystems without hard links,
+// such as FAT, exFAT and many network and FUSE mounts, can only be written
+// with overwrite set.
+package cryptopasta
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+)
+
+// EncryptFile encrypts the file at src and writes the result to dst with
+// permissions 0600. It fails if dst already exists unless overwrite is true.
+func EncryptFile(src, dst string, key *[32]byte, overwrite bool) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFileAtomic(dst, overwrite, func(out io.Writer) error {
+		w, err := NewEncryptWriter(out, key)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, in); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+}
+
+// DecryptFile decrypts a file produced by EncryptFile and writes the result to
+// dst with permissions 0600. It fails if dst already exists unless overwrite
+// is true. If the file was altered or truncated, dst is left untouched.
+func DecryptFile(src, dst string, key *[32]byte, overwrite bool) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFileAtomic(dst, overwrite, func(out io.Writer) error {
+		r, err := NewDecryptReader(in, key)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, r)
+		return err
+	})
+}
+
+// writeFileAtomic calls write with a temporary file next to dst and, if it
+// succeeds, moves the file to dst. Without overwrite, the move is a hard link
+// so that it fails rather than replacing a file created in the meantime.
+func writeFileAtomic(dst string, overwrite bool, write func(io.Writer) error) error {
+	if !overwrite {
+		if _, err := os.Lstat(dst); err == nil {
+			return &os.PathError{Op: "create", Path: dst, Err: os.ErrExist}
+		}
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	if err := tmp.Chmod(0600); err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if overwrite {
+		if err := os.Rename(tmp.Name(), dst); err != nil {
+			return err
+		}
+	} else if err := linkFile(tmp.Name(), dst); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) || errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("file: %s: filesystem does not support hard links, "+
+				"which writing without overwrite needs; use overwrite=true: %w", dst, err)
+		}
+		return err
+	}
+
+	return syncDir(filepath.Dir(dst))
+}
+
+// linkFile is os.Link, replaced in tests to simulate filesystems without hard
+// links.
+var linkFile = os.Link
+
+// syncDir flushes changes to a directory's entries to disk. Windows and
+// filesystems that can't sync directories are skipped.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	err = d.Sync()
+	if errors.Is(err, errors.ErrUnsupported) || errors.Is(err, syscall.EINVAL) {
+		return nil
+	}
+	return err
+}
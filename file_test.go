@@ -0,0 +1,163 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestEncryptDecryptFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cryptopasta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := NewEncryptionKey()
+	encrypted := filepath.Join(dir, "big.enc")
+	decrypted := filepath.Join(dir, "big")
+
+	if err := EncryptFile("testdata/big", encrypted, key, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile(encrypted, decrypted, key, false); err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := ioutil.ReadFile("testdata/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ioutil.ReadFile(decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, result) {
+		t.Error("decrypted file doesn't match")
+	}
+
+	for _, name := range []string{encrypted, decrypted} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0600 {
+			t.Errorf("%s has permissions %v", name, fi.Mode().Perm())
+		}
+	}
+
+	// Refuse to overwrite unless asked.
+	err = EncryptFile("testdata/big", encrypted, key, false)
+	if !os.IsExist(err) {
+		t.Errorf("expected an exists error, got %v", err)
+	}
+	if err := EncryptFile("testdata/random", encrypted, key, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile(encrypted, decrypted, key, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// No temporary files are left behind.
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected 2 files, found %d", len(entries))
+	}
+}
+
+func TestDecryptFileTampered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cryptopasta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := NewEncryptionKey()
+	encrypted := filepath.Join(dir, "big.enc")
+	decrypted := filepath.Join(dir, "big")
+
+	if err := EncryptFile("testdata/big", encrypted, key, false); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Damage the last segment, so most of the file decrypts before the
+	// error is found.
+	data[len(data)-1] ^= 0xff
+	if err := ioutil.WriteFile(encrypted, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DecryptFile(encrypted, decrypted, key, false); err == nil {
+		t.Fatal("decrypted a tampered file")
+	}
+
+	if _, err := os.Stat(decrypted); !os.IsNotExist(err) {
+		t.Error("partial plaintext was left at the destination")
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected 1 file, found %d", len(entries))
+	}
+}
+
+func TestEncryptFileNoHardLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cryptopasta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// vfat reports EPERM for link(2); others report ENOTSUP or ENOSYS.
+	defer func(link func(string, string) error) { linkFile = link }(linkFile)
+	for _, errno := range []syscall.Errno{syscall.EPERM, syscall.ENOSYS} {
+		linkFile = func(oldname, newname string) error {
+			return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: errno}
+		}
+
+		key := NewEncryptionKey()
+		encrypted := filepath.Join(dir, "random.enc")
+		err := EncryptFile("testdata/random", encrypted, key, false)
+		if err == nil || !strings.Contains(err.Error(), "overwrite=true") {
+			t.Errorf("%v: expected an error suggesting overwrite, got %v", errno, err)
+		}
+		if !errors.Is(err, errno) {
+			t.Errorf("%v: error doesn't wrap the cause", errno)
+		}
+
+		// The temporary file is cleaned up, and overwrite still works.
+		if entries, _ := ioutil.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("%v: expected no files, found %d", errno, len(entries))
+		}
+		if err := EncryptFile("testdata/random", encrypted, key, true); err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(encrypted)
+	}
+}
@@ -0,0 +1,282 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides nonce-misuse-resistant authenticated encryption using
+// AES-256-GCM-SIV as described in RFC 8452.
+//
+// With plain AES-GCM, encrypting two messages under the same key and nonce
+// reveals their XOR and lets an attacker forge messages. GCM-SIV derives its
+// IV from the message itself, so a repeated nonce only reveals whether two
+// messages were identical. Use it when you can't be sure of your random number
+// generator; it costs a second pass over the data.
+//
+// POLYVAL is computed one bit at a time with masks rather than branches or
+// tables, so it is constant time but slow.
+package cryptopasta
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// EncryptSIV encrypts data using AES-256-GCM-SIV with a random 96-bit nonce.
+// Output takes the form nonce|ciphertext|tag where '|' indicates
+// concatenation, just like Encrypt.
+func EncryptSIV(plaintext []byte, key *[32]byte) (ciphertext []byte, err error) {
+	aead, err := newGCMSIV(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// DecryptSIV decrypts data using AES-256-GCM-SIV. Expects input form
+// nonce|ciphertext|tag where '|' indicates concatenation.
+func DecryptSIV(ciphertext []byte, key *[32]byte) (plaintext []byte, err error) {
+	aead, err := newGCMSIV(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+
+	return aead.Open(nil,
+		ciphertext[:aead.NonceSize()],
+		ciphertext[aead.NonceSize():],
+		nil,
+	)
+}
+
+const (
+	gcmSIVNonceSize = 12
+	gcmSIVTagSize   = 16
+	gcmSIVMaxLength = 1 << 36
+)
+
+// gcmSIV implements cipher.AEAD for AES-256-GCM-SIV.
+type gcmSIV struct {
+	block cipher.Block // keyed with the key-generating key
+}
+
+func newGCMSIV(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("gcmsiv: key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmSIV{block: block}, nil
+}
+
+func (g *gcmSIV) NonceSize() int { return gcmSIVNonceSize }
+func (g *gcmSIV) Overhead() int  { return gcmSIVTagSize }
+
+func (g *gcmSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("gcmsiv: incorrect nonce length")
+	}
+	if uint64(len(plaintext)) > gcmSIVMaxLength || uint64(len(additionalData)) > gcmSIVMaxLength {
+		panic("gcmsiv: message too large")
+	}
+
+	authKey, encBlock := g.deriveKeys(nonce)
+	tag := gcmSIVTag(authKey, encBlock, nonce, plaintext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+gcmSIVTagSize)
+	gcmSIVCTR(encBlock, tag, out, plaintext)
+	copy(out[len(plaintext):], tag[:])
+	return ret
+}
+
+func (g *gcmSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("gcmsiv: incorrect nonce length")
+	}
+	if len(ciphertext) < gcmSIVTagSize ||
+		uint64(len(ciphertext)) > gcmSIVMaxLength+gcmSIVTagSize ||
+		uint64(len(additionalData)) > gcmSIVMaxLength {
+		return nil, errors.New("gcmsiv: message authentication failed")
+	}
+
+	var tag [16]byte
+	copy(tag[:], ciphertext[len(ciphertext)-gcmSIVTagSize:])
+	ciphertext = ciphertext[:len(ciphertext)-gcmSIVTagSize]
+
+	authKey, encBlock := g.deriveKeys(nonce)
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	gcmSIVCTR(encBlock, tag, out, ciphertext)
+
+	expected := gcmSIVTag(authKey, encBlock, nonce, out, additionalData)
+	if subtle.ConstantTimeCompare(expected[:], tag[:]) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errors.New("gcmsiv: message authentication failed")
+	}
+
+	return ret, nil
+}
+
+// deriveKeys computes the per-nonce message-authentication key and
+// message-encryption key from the key-generating key (RFC 8452 section 4).
+func (g *gcmSIV) deriveKeys(nonce []byte) (authKey [16]byte, encBlock cipher.Block) {
+	var in, out [16]byte
+	var encKey [32]byte
+	copy(in[4:], nonce)
+
+	for i := uint32(0); i < 6; i++ {
+		binary.LittleEndian.PutUint32(in[:4], i)
+		g.block.Encrypt(out[:], in[:])
+		if i < 2 {
+			copy(authKey[i*8:], out[:8])
+		} else {
+			copy(encKey[(i-2)*8:], out[:8])
+		}
+	}
+
+	encBlock, err := aes.NewCipher(encKey[:])
+	if err != nil {
+		panic(err) // can't happen with a 32-byte key
+	}
+	return authKey, encBlock
+}
+
+// gcmSIVTag computes the tag over the plaintext and additional data.
+func gcmSIVTag(authKey [16]byte, encBlock cipher.Block, nonce, plaintext, additionalData []byte) [16]byte {
+	p := newPolyval(authKey)
+	p.update(additionalData)
+	p.update(plaintext)
+
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(plaintext))*8)
+	p.update(lengths[:])
+
+	s := p.sum()
+	for i := range nonce {
+		s[i] ^= nonce[i]
+	}
+	s[15] &= 0x7f
+
+	var tag [16]byte
+	encBlock.Encrypt(tag[:], s[:])
+	return tag
+}
+
+// gcmSIVCTR XORs in with the keystream whose initial counter block is the tag
+// with its top bit set. Only the first 32 bits count, little-endian.
+func gcmSIVCTR(encBlock cipher.Block, tag [16]byte, out, in []byte) {
+	counter := tag
+	counter[15] |= 0x80
+
+	var keystream [16]byte
+	for len(in) > 0 {
+		encBlock.Encrypt(keystream[:], counter[:])
+		n := len(in)
+		if n > 16 {
+			n = 16
+		}
+		subtle.XORBytes(out[:n], in[:n], keystream[:n])
+		out, in = out[n:], in[n:]
+
+		c := binary.LittleEndian.Uint32(counter[:4])
+		binary.LittleEndian.PutUint32(counter[:4], c+1)
+	}
+}
+
+// polyval computes POLYVAL over 16-byte blocks, zero-padding each call to
+// update to a whole number of blocks. Field elements are held as two
+// little-endian 64-bit halves.
+type polyval struct {
+	h      [2]uint64 // H·x^-128, so each step needs only one multiplication
+	s      [2]uint64
+	buffer [16]byte
+}
+
+// x^-128 in the POLYVAL field is x^127 + x^124 + x^121 + x^114 + 1.
+var polyvalXInv128 = [2]uint64{1, 1<<63 | 1<<60 | 1<<57 | 1<<50}
+
+func newPolyval(key [16]byte) *polyval {
+	h := [2]uint64{
+		binary.LittleEndian.Uint64(key[:8]),
+		binary.LittleEndian.Uint64(key[8:]),
+	}
+	return &polyval{h: gfMul(h, polyvalXInv128)}
+}
+
+func (p *polyval) update(data []byte) {
+	for len(data) > 0 {
+		n := copy(p.buffer[:], data)
+		for i := n; i < 16; i++ {
+			p.buffer[i] = 0
+		}
+		data = data[n:]
+
+		p.s[0] ^= binary.LittleEndian.Uint64(p.buffer[:8])
+		p.s[1] ^= binary.LittleEndian.Uint64(p.buffer[8:])
+		p.s = gfMul(p.s, p.h)
+	}
+}
+
+func (p *polyval) sum() [16]byte {
+	var out [16]byte
+	binary.LittleEndian.PutUint64(out[:8], p.s[0])
+	binary.LittleEndian.PutUint64(out[8:], p.s[1])
+	return out
+}
+
+// gfMul multiplies a and b modulo x^128 + x^127 + x^126 + x^121 + 1.
+func gfMul(a, b [2]uint64) [2]uint64 {
+	var r [2]uint64
+	for i := uint(0); i < 128; i++ {
+		bit := (b[i/64] >> (i % 64)) & 1
+		mask := -bit
+		r[0] ^= a[0] & mask
+		r[1] ^= a[1] & mask
+
+		// a = a·x, reducing x^128 to x^127 + x^126 + x^121 + 1.
+		carry := -(a[1] >> 63)
+		a[1] = a[1]<<1 | a[0]>>63
+		a[0] <<= 1
+		a[0] ^= 1 & carry
+		a[1] ^= (1<<63 | 1<<62 | 1<<57) & carry
+	}
+	return r
+}
+
+// sliceForAppend extends in by n bytes, returning the whole slice and the
+// newly added tail.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,157 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+)
+
+// https://tools.ietf.org/html/rfc8452#appendix-C.2 and C.3
+var gcmSIVTests = []struct {
+	key        string
+	nonce      string
+	aad        string
+	plaintext  string
+	ciphertext string
+}{
+	{
+		key:        "0100000000000000000000000000000000000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		aad:        "",
+		plaintext:  "",
+		ciphertext: "07f5f4169bbf55a8400cd47ea6fd400f",
+	},
+	{
+		key:        "0100000000000000000000000000000000000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		aad:        "",
+		plaintext:  "0100000000000000",
+		ciphertext: "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28",
+	},
+	{
+		key:        "0100000000000000000000000000000000000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		aad:        "",
+		plaintext:  "010000000000000000000000",
+		ciphertext: "9aab2aeb3faa0a34aea8e2b18ca50da9ae6559e48fd10f6e5c9ca17e",
+	},
+	{
+		key:        "0100000000000000000000000000000000000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		aad:        "01",
+		plaintext:  "0200000000000000",
+		ciphertext: "1de22967237a813291213f267e3b452f02d01ae33e4ec854",
+	},
+	{
+		// Counter wraparound
+		key:        "0000000000000000000000000000000000000000000000000000000000000000",
+		nonce:      "000000000000000000000000",
+		aad:        "",
+		plaintext:  "000000000000000000000000000000004db923dc793ee6497c76dcc03a98e108",
+		ciphertext: "f3f80f2cf0cb2dd9c5984fcda908456cc537703b5ba70324a6793a7bf218d3eaffffffff000000000000000000000000",
+	},
+}
+
+func TestGCMSIVVectors(t *testing.T) {
+	for idx, tt := range gcmSIVTests {
+		key, _ := hex.DecodeString(tt.key)
+		nonce, _ := hex.DecodeString(tt.nonce)
+		aad, _ := hex.DecodeString(tt.aad)
+		plaintext, _ := hex.DecodeString(tt.plaintext)
+		expected, _ := hex.DecodeString(tt.ciphertext)
+
+		aead, err := newGCMSIV(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ciphertext := aead.Seal(nil, nonce, plaintext, aad)
+		if !bytes.Equal(ciphertext, expected) {
+			t.Errorf("test %d: got %x, expected %x", idx, ciphertext, expected)
+		}
+
+		decrypted, err := aead.Open(nil, nonce, expected, aad)
+		if err != nil {
+			t.Errorf("test %d: %v", idx, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("test %d: plaintexts don't match", idx)
+		}
+
+		expected[0] ^= 0x01
+		if _, err := aead.Open(nil, nonce, expected, aad); err == nil {
+			t.Errorf("test %d: opened an altered ciphertext", idx)
+		}
+	}
+}
+
+func TestEncryptDecryptSIV(t *testing.T) {
+	key := NewEncryptionKey()
+	message := []byte("Hello, world!")
+
+	ciphertext, err := EncryptSIV(message, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := DecryptSIV(ciphertext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(plaintext, message) {
+		t.Errorf("plaintexts don't match")
+	}
+
+	ciphertext[len(ciphertext)-1] ^= 0xff
+	if _, err := DecryptSIV(ciphertext, key); err == nil {
+		t.Errorf("gcmsiv open should not have worked, but did")
+	}
+}
+
+func TestGCMSIVNonceReuse(t *testing.T) {
+	key := NewEncryptionKey()
+	aead, err := newGCMSIV(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+
+	a := aead.Seal(nil, nonce, []byte("attack at dawn"), nil)
+	b := aead.Seal(nil, nonce, []byte("attack at dusk"), nil)
+	c := aead.Seal(nil, nonce, []byte("attack at dawn"), nil)
+
+	// A repeated nonce reveals equality of messages and nothing else.
+	if !bytes.Equal(a, c) {
+		t.Error("identical messages produced different ciphertexts")
+	}
+	if bytes.Equal(a[:9], b[:9]) {
+		t.Error("messages with a common prefix share a keystream")
+	}
+}
+
+func BenchmarkAESGCMSIV(b *testing.B) {
+	key := NewEncryptionKey()
+
+	data, err := ioutil.ReadFile("testdata/big")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		EncryptSIV(data, key)
+	}
+}
@@ -16,14 +16,13 @@
 // maintaining a widely compatible digest size with better performance on
 // 64-bit systems.
 //
-// Password hashing uses bcrypt with a work factor of 14.
+// Password hashing uses bcrypt with a work factor of 14, or Argon2id with
+// HashPasswordArgon2id.
 package cryptopasta
 
 import (
 	"crypto/hmac"
 	"crypto/sha512"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Hash generates a hash of data using HMAC-SHA-512/256. The tag is intended to
@@ -37,13 +36,18 @@ func Hash(tag string, data []byte) []byte {
 	return h.Sum(nil)
 }
 
+// bcryptCost is the work factor HashPassword uses.
+const bcryptCost = 14
+
 // HashPassword generates a bcrypt hash of the password using work factor 14.
+// Use a PasswordHasher for other algorithms or costs.
 func HashPassword(password []byte) ([]byte, error) {
-	return bcrypt.GenerateFromPassword(password, 14)
+	return NewPasswordHasher().Hash(password)
 }
 
-// CheckPassword securely compares a bcrypt hashed password with its possible
-// plaintext equivalent.  Returns nil on success, or an error on failure.
+// CheckPassword securely compares a bcrypt or Argon2id hashed password with
+// its possible plaintext equivalent. The format is detected from the hash.
+// Returns nil on success, or an error on failure.
 func CheckPasswordHash(hash, password []byte) error {
-	return bcrypt.CompareHashAndPassword(hash, password)
+	return NewPasswordHasher().Check(hash, password)
 }
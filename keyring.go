@@ -0,0 +1,137 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides key rotation for symmetric encryption.
+//
+// A KeyRing holds several encryption keys under short string IDs. New data is
+// always encrypted under the primary key, and the versioned ciphertext records
+// that key's ID so that it can still be decrypted after the primary has been
+// rotated. Old keys stay in the ring until everything encrypted under them has
+// been migrated with ReEncrypt.
+package cryptopasta
+
+import (
+	"errors"
+	"sync"
+)
+
+// KeyRing is a set of named 256-bit encryption keys with a designated
+// primary. It is safe for concurrent use.
+type KeyRing struct {
+	mu      sync.RWMutex
+	keys    map[string]*[32]byte
+	primary string
+}
+
+// NewKeyRing returns a KeyRing with key as its primary, named by id.
+func NewKeyRing(id string, key *[32]byte) (*KeyRing, error) {
+	kr := &KeyRing{keys: make(map[string]*[32]byte)}
+	if err := kr.Add(id, key); err != nil {
+		return nil, err
+	}
+	kr.primary = id
+	return kr, nil
+}
+
+// Add puts a key in the ring without making it the primary. Use it to load
+// keys that are only needed to decrypt existing data.
+func (kr *KeyRing) Add(id string, key *[32]byte) error {
+	if id == "" || len(id) > 255 {
+		return errors.New("key ID must be between 1 and 255 bytes")
+	}
+	if key == nil {
+		return errors.New("missing key")
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if _, ok := kr.keys[id]; ok {
+		return errors.New("duplicate key ID")
+	}
+	kr.keys[id] = key
+	return nil
+}
+
+// Rotate adds a new key and makes it the primary. The previous primary is
+// kept for decryption.
+func (kr *KeyRing) Rotate(id string, key *[32]byte) error {
+	if err := kr.Add(id, key); err != nil {
+		return err
+	}
+
+	kr.mu.Lock()
+	kr.primary = id
+	kr.mu.Unlock()
+	return nil
+}
+
+// Remove deletes a key that is no longer needed. The primary key can't be
+// removed.
+func (kr *KeyRing) Remove(id string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if id == kr.primary {
+		return errors.New("can't remove the primary key")
+	}
+	if _, ok := kr.keys[id]; !ok {
+		return errors.New("unknown key ID")
+	}
+	delete(kr.keys, id)
+	return nil
+}
+
+// Primary returns the ID of the key used for new encryptions.
+func (kr *KeyRing) Primary() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.primary
+}
+
+// Encrypt encrypts data under the primary key using 256-bit AES-GCM. Output is
+// a versioned ciphertext that records the primary key's ID.
+func (kr *KeyRing) Encrypt(plaintext []byte) ([]byte, error) {
+	kr.mu.RLock()
+	id, key := kr.primary, kr.keys[kr.primary]
+	kr.mu.RUnlock()
+
+	return EncryptVersioned(plaintext, AES256GCM, id, key)
+}
+
+// Decrypt decrypts data produced by Encrypt using the key named in its header,
+// which need not be the current primary.
+func (kr *KeyRing) Decrypt(ciphertext []byte) ([]byte, error) {
+	id, ok := VersionedKeyID(ciphertext)
+	if !ok {
+		return nil, errors.New("ciphertext has no key ID")
+	}
+
+	kr.mu.RLock()
+	key, ok := kr.keys[id]
+	kr.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("unknown key ID")
+	}
+
+	return DecryptVersioned(ciphertext, key)
+}
+
+// ReEncrypt decrypts data and encrypts it again under the current primary
+// key. Run stored ciphertexts through it after Rotate so the old key can
+// eventually be removed.
+func (kr *KeyRing) ReEncrypt(ciphertext []byte) ([]byte, error) {
+	plaintext, err := kr.Decrypt(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return kr.Encrypt(plaintext)
+}
@@ -0,0 +1,120 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeyRingRotation(t *testing.T) {
+	message := []byte("Hello, world!")
+
+	kr, err := NewKeyRing("2016-01", NewEncryptionKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldCiphertext, err := kr.Encrypt(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id, _ := VersionedKeyID(oldCiphertext); id != "2016-01" {
+		t.Errorf("ciphertext names key %q, expected 2016-01", id)
+	}
+
+	if err := kr.Rotate("2016-02", NewEncryptionKey()); err != nil {
+		t.Fatal(err)
+	}
+
+	if kr.Primary() != "2016-02" {
+		t.Errorf("primary is %q after rotation", kr.Primary())
+	}
+
+	// Data from before the rotation must still decrypt.
+	plaintext, err := kr.Decrypt(oldCiphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, message) {
+		t.Errorf("plaintexts don't match")
+	}
+
+	newCiphertext, err := kr.ReEncrypt(oldCiphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id, _ := VersionedKeyID(newCiphertext); id != "2016-02" {
+		t.Errorf("re-encrypted ciphertext names key %q, expected 2016-02", id)
+	}
+
+	if err := kr.Remove("2016-01"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := kr.Decrypt(oldCiphertext); err == nil {
+		t.Error("decrypted under a removed key")
+	}
+
+	plaintext, err = kr.Decrypt(newCiphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, message) {
+		t.Errorf("plaintexts don't match")
+	}
+}
+
+func TestKeyRingErrors(t *testing.T) {
+	key := NewEncryptionKey()
+
+	if _, err := NewKeyRing("", key); err == nil {
+		t.Error("accepted an empty key ID")
+	}
+
+	kr, err := NewKeyRing("a", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := kr.Add("a", NewEncryptionKey()); err == nil {
+		t.Error("accepted a duplicate key ID")
+	}
+
+	if err := kr.Remove("a"); err == nil {
+		t.Error("removed the primary key")
+	}
+
+	// Ciphertexts from another ring with the same key ID must not decrypt.
+	other, err := NewKeyRing("a", NewEncryptionKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := other.Encrypt([]byte("Hello, world!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kr.Decrypt(ciphertext); err == nil {
+		t.Error("decrypted another ring's ciphertext")
+	}
+
+	// Nor must anything without a key ID.
+	ciphertext, err = EncryptVersioned([]byte("Hello, world!"), AES256GCM, "", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kr.Decrypt(ciphertext); err == nil {
+		t.Error("decrypted a ciphertext without a key ID")
+	}
+}
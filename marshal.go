@@ -13,12 +13,20 @@
 package cryptopasta
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/crypto/cryptobyte/asn1"
 )
 
 // DecodePublicKey decodes a PEM-encoded ECDSA public key.
@@ -100,6 +108,152 @@ func EncodePrivateKey(key *ecdsa.PrivateKey) ([]byte, error) {
 	return pem.EncodeToMemory(keyBlock), nil
 }
 
+// DecodeEd25519PublicKey decodes a PEM-encoded Ed25519 public key.
+func DecodeEd25519PublicKey(encodedKey []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(encodedKey)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("marshal: could not decode PEM block type PUBLIC KEY")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	edPub, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("marshal: data was not an Ed25519 public key")
+	}
+
+	return edPub, nil
+}
+
+// EncodeEd25519PublicKey encodes an Ed25519 public key to PEM format.
+func EncodeEd25519PublicKey(key ed25519.PublicKey) ([]byte, error) {
+	derBytes, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	block := &pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	}
+
+	return pem.EncodeToMemory(block), nil
+}
+
+// DecodeEd25519PrivateKey decodes a PEM-encoded PKCS#8 Ed25519 private key.
+func DecodeEd25519PrivateKey(encodedKey []byte) (ed25519.PrivateKey, error) {
+	var skippedTypes []string
+	var block *pem.Block
+
+	for {
+		block, encodedKey = pem.Decode(encodedKey)
+
+		if block == nil {
+			return nil, fmt.Errorf("failed to find PRIVATE KEY in PEM data after skipping types %v", skippedTypes)
+		}
+
+		if block.Type == "PRIVATE KEY" {
+			break
+		} else {
+			skippedTypes = append(skippedTypes, block.Type)
+			continue
+		}
+	}
+
+	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	edPriv, ok := privKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("marshal: data was not an Ed25519 private key")
+	}
+
+	return edPriv, nil
+}
+
+// EncodeEd25519PrivateKey encodes an Ed25519 private key to PEM format using
+// PKCS#8.
+func EncodeEd25519PrivateKey(key ed25519.PrivateKey) ([]byte, error) {
+	derKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBlock := &pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: derKey,
+	}
+
+	return pem.EncodeToMemory(keyBlock), nil
+}
+
+// Fingerprint returns a stable identifier for an ECDSA or Ed25519 public key:
+// the SHA-256 digest of its DER-encoded SubjectPublicKeyInfo, the same bytes
+// EncodePublicKey wraps in PEM. Every encoding of a key has the same
+// fingerprint.
+func Fingerprint(pub crypto.PublicKey) ([]byte, error) {
+	switch pub.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+	default:
+		return nil, errors.New("marshal: unsupported public key type")
+	}
+
+	derBytes, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(derBytes)
+	return digest[:], nil
+}
+
+// FormatFingerprint formats a fingerprint for people to read and compare, as
+// "SHA256:" followed by unpadded base64. The result makes a good key ID for a
+// KeyRing or SignMessage.
+func FormatFingerprint(fingerprint []byte) string {
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(fingerprint)
+}
+
+// JWKThumbprint returns the RFC 7638 SHA-256 thumbprint of an ECDSA or Ed25519
+// public key, base64url-encoded without padding as JOSE uses it, e.g. for the
+// "kid" of a JWK.
+func JWKThumbprint(pub crypto.PublicKey) (string, error) {
+	var canonical string
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if _, err := algorithmForCurve(k.Curve); err != nil {
+			return "", errors.New("marshal: unsupported curve")
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		x := make([]byte, size)
+		y := make([]byte, size)
+		k.X.FillBytes(x)
+		k.Y.FillBytes(y)
+		// members in lexicographic order, no whitespace (RFC 7638 section 3.2)
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`,
+			k.Curve.Params().Name,
+			base64.RawURLEncoding.EncodeToString(x),
+			base64.RawURLEncoding.EncodeToString(y))
+	case ed25519.PublicKey:
+		if len(k) != ed25519.PublicKeySize {
+			return "", errors.New("marshal: bad Ed25519 public key length")
+		}
+		// RFC 8037 section 2
+		canonical = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`,
+			base64.RawURLEncoding.EncodeToString(k))
+	default:
+		return "", errors.New("marshal: unsupported public key type")
+	}
+
+	digest := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(digest[:]), nil
+}
+
 // Encodes an ECDSA signature according to
 // https://tools.ietf.org/html/rfc7515#appendix-A.3.1
 func EncodeSignatureJWT(sig []byte) string {
@@ -111,3 +265,46 @@ func EncodeSignatureJWT(sig []byte) string {
 func DecodeSignatureJWT(b64sig string) ([]byte, error) {
 	return base64.RawURLEncoding.DecodeString(b64sig)
 }
+
+// SignatureToASN1 converts a raw r||s ECDSA signature for the given curve, as
+// produced by Sign, to the ASN.1 DER Ecdsa-Sig-Value used by OpenSSL, X.509
+// and most HSMs.
+func SignatureToASN1(sig []byte, curve elliptic.Curve) ([]byte, error) {
+	curveOrderByteSize := curveOrderSize(curve)
+	if len(sig) != 2*curveOrderByteSize {
+		return nil, errors.New("marshal: signature has wrong length for curve")
+	}
+
+	r := new(big.Int).SetBytes(sig[:curveOrderByteSize])
+	s := new(big.Int).SetBytes(sig[curveOrderByteSize:])
+
+	var b cryptobyte.Builder
+	b.AddASN1(asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1BigInt(r)
+		b.AddASN1BigInt(s)
+	})
+	return b.Bytes()
+}
+
+// SignatureFromASN1 converts an ASN.1 DER Ecdsa-Sig-Value to the raw r||s
+// format for the given curve. Parsing is strict: BER-only forms, non-minimal
+// integers, trailing data, and values of r or s outside [1, N-1] are rejected.
+func SignatureFromASN1(der []byte, curve elliptic.Curve) ([]byte, error) {
+	r, s := new(big.Int), new(big.Int)
+	input := cryptobyte.String(der)
+	var inner cryptobyte.String
+	if !input.ReadASN1(&inner, asn1.SEQUENCE) ||
+		!input.Empty() ||
+		!inner.ReadASN1Integer(r) ||
+		!inner.ReadASN1Integer(s) ||
+		!inner.Empty() {
+		return nil, errors.New("marshal: malformed ASN.1 signature")
+	}
+
+	n := curve.Params().N
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return nil, errors.New("marshal: signature values out of range")
+	}
+
+	return encodeSignature(r, s, curve), nil
+}
@@ -0,0 +1,157 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides a signed message format that carries its payload, signature and
+// signer together.
+//
+// The signature covers the algorithm and key ID as well as the payload, so
+// neither can be changed to point a verifier at a different key. Each field is
+// prefixed with its length so that no two messages share a signing input. The
+// algorithm names are the JWS ones from RFC 7518 and are tied to the curve of
+// the key, which prevents a message signed for one curve being checked with
+// another.
+//
+// A marshaled message is plain JSON with base64 payload and signature, so it
+// can be stored or sent anywhere text goes. Nothing in it is secret.
+package cryptopasta
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// SignedMessage is a payload together with a signature over it and the
+// information needed to pick the key that checks it.
+type SignedMessage struct {
+	Payload   []byte `json:"payload"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Signature []byte `json:"sig"`
+}
+
+var signedMessageContext = []byte("cryptopasta signed message v1")
+
+// SignMessage signs payload with privkey and returns the marshaled
+// SignedMessage. keyID names the key for OpenMessage to look up.
+func SignMessage(payload []byte, keyID string, privkey *ecdsa.PrivateKey) ([]byte, error) {
+	alg, err := algorithmForCurve(privkey.Curve)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &SignedMessage{
+		Payload:   payload,
+		Algorithm: alg,
+		KeyID:     keyID,
+	}
+	m.Signature, err = Sign(m.signingInput(), privkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Marshal()
+}
+
+// OpenMessage parses a message made by SignMessage, verifies it with the key
+// trusted holds for its key ID, and returns the payload. The payload is only
+// returned if the signature is valid.
+func OpenMessage(data []byte, trusted map[string]*ecdsa.PublicKey) ([]byte, error) {
+	var m SignedMessage
+	if err := m.Unmarshal(data); err != nil {
+		return nil, err
+	}
+
+	pubkey, ok := trusted[m.KeyID]
+	if !ok || pubkey == nil {
+		return nil, errors.New("signed message: unknown key ID")
+	}
+
+	alg, err := algorithmForCurve(pubkey.Curve)
+	if err != nil {
+		return nil, err
+	}
+	if alg != m.Algorithm {
+		return nil, errors.New("signed message: algorithm does not match key")
+	}
+
+	if err := VerifyErr(m.signingInput(), m.Signature, pubkey); err != nil {
+		return nil, err
+	}
+
+	return m.Payload, nil
+}
+
+// Marshal encodes the message as JSON.
+func (m *SignedMessage) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Unmarshal decodes a message encoded by Marshal. It checks that the message
+// is well formed, not that its signature is valid; use OpenMessage for that.
+func (m *SignedMessage) Unmarshal(data []byte) error {
+	var parsed SignedMessage
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	if curveForAlgorithm(parsed.Algorithm) == nil {
+		return errors.New("signed message: unsupported algorithm")
+	}
+	if len(parsed.Signature) == 0 {
+		return errors.New("signed message: missing signature")
+	}
+
+	*m = parsed
+	return nil
+}
+
+// signingInput encodes the fields the signature covers, each prefixed with
+// its length as a big-endian uint64.
+func (m *SignedMessage) signingInput() []byte {
+	fields := [][]byte{signedMessageContext, []byte(m.Algorithm), []byte(m.KeyID), m.Payload}
+
+	var out []byte
+	var length [8]byte
+	for _, f := range fields {
+		binary.BigEndian.PutUint64(length[:], uint64(len(f)))
+		out = append(out, length[:]...)
+		out = append(out, f...)
+	}
+	return out
+}
+
+// algorithmForCurve returns the RFC 7518 name for ECDSA on curve with the
+// digest Sign uses.
+func algorithmForCurve(curve elliptic.Curve) (string, error) {
+	switch curve {
+	case elliptic.P256():
+		return "ES256", nil
+	case elliptic.P384():
+		return "ES384", nil
+	case elliptic.P521():
+		return "ES512", nil
+	}
+	return "", errors.New("signed message: unsupported curve")
+}
+
+func curveForAlgorithm(alg string) elliptic.Curve {
+	switch alg {
+	case "ES256":
+		return elliptic.P256()
+	case "ES384":
+		return elliptic.P384()
+	case "ES512":
+		return elliptic.P521()
+	}
+	return nil
+}
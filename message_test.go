@@ -0,0 +1,148 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSignOpenMessage(t *testing.T) {
+	payload := []byte("Hello, world!")
+
+	for _, tt := range []struct {
+		curve elliptic.Curve
+		alg   string
+	}{
+		{elliptic.P256(), "ES256"},
+		{elliptic.P384(), "ES384"},
+		{elliptic.P521(), "ES512"},
+	} {
+		key, err := ecdsa.GenerateKey(tt.curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := SignMessage(payload, "signer-1", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var m SignedMessage
+		if err := m.Unmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+		if m.Algorithm != tt.alg || m.KeyID != "signer-1" || !bytes.Equal(m.Payload, payload) {
+			t.Errorf("%s: unexpected message %+v", tt.alg, m)
+		}
+
+		trusted := map[string]*ecdsa.PublicKey{"signer-1": &key.PublicKey}
+		opened, err := OpenMessage(data, trusted)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.alg, err)
+		}
+		if !bytes.Equal(opened, payload) {
+			t.Errorf("%s: payloads don't match", tt.alg)
+		}
+	}
+}
+
+func TestOpenMessageRejects(t *testing.T) {
+	payload := []byte("Hello, world!")
+
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := SignMessage(payload, "signer-1", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tamper := func(f func(m *SignedMessage)) []byte {
+		var m SignedMessage
+		if err := m.Unmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+		f(&m)
+		out, err := m.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	trusted := map[string]*ecdsa.PublicKey{
+		"signer-1": &key.PublicKey,
+		"signer-2": &other.PublicKey,
+		"signer-3": &p384.PublicKey,
+	}
+
+	rejectTests := []struct {
+		name string
+		data []byte
+	}{
+		{"garbage", []byte("not a message")},
+		{"altered payload", tamper(func(m *SignedMessage) { m.Payload = []byte("Goodbye, world!") })},
+		{"unknown key ID", tamper(func(m *SignedMessage) { m.KeyID = "signer-4" })},
+		{"other key ID", tamper(func(m *SignedMessage) { m.KeyID = "signer-2" })},
+		{"algorithm for other curve", tamper(func(m *SignedMessage) { m.Algorithm = "ES384"; m.KeyID = "signer-3" })},
+		{"unsupported algorithm", tamper(func(m *SignedMessage) { m.Algorithm = "none" })},
+		{"missing signature", tamper(func(m *SignedMessage) { m.Signature = nil })},
+		{"truncated signature", tamper(func(m *SignedMessage) { m.Signature = m.Signature[:32] })},
+	}
+
+	for _, tt := range rejectTests {
+		opened, err := OpenMessage(tt.data, trusted)
+		if err == nil {
+			t.Errorf("%s: message was accepted", tt.name)
+		}
+		if opened != nil {
+			t.Errorf("%s: payload returned with error", tt.name)
+		}
+	}
+}
+
+func TestSignedMessageFieldsAreBound(t *testing.T) {
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Moving bytes between the key ID and the payload must not leave the
+	// signing input unchanged.
+	a := &SignedMessage{Algorithm: "ES256", KeyID: "ab", Payload: []byte("c")}
+	b := &SignedMessage{Algorithm: "ES256", KeyID: "a", Payload: []byte("bc")}
+	if bytes.Equal(a.signingInput(), b.signingInput()) {
+		t.Fatal("different messages have the same signing input")
+	}
+
+	signature, err := Sign(a.signingInput(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Verify(b.signingInput(), signature, &key.PublicKey) {
+		t.Error("signature carried over to a different split")
+	}
+}
@@ -0,0 +1,173 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides encryption under a user-supplied password.
+//
+// Passwords are far weaker than random keys, so the 256-bit key for AES-GCM is
+// derived with Argon2id, a memory-hard function that makes each guess
+// expensive. Output takes the form header|nonce|ciphertext|tag where the header
+// holds a version byte, the Argon2id parameters and a random 16-byte salt. The
+// header is authenticated along with the ciphertext.
+//
+// Don't be tempted to use HashPassword output as a key instead. bcrypt hashes
+// are designed to be stored, not kept secret, and truncating one leaves far
+// less than 256 bits of strength.
+package cryptopasta
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Params are the cost parameters for Argon2id.
+type Argon2Params struct {
+	Time    uint32 // number of passes over memory
+	Memory  uint32 // memory size in KiB
+	Threads uint8  // degree of parallelism
+}
+
+// DefaultArgon2Params follow the second recommended option of RFC 9106: three
+// passes over 64MiB with four lanes.
+var DefaultArgon2Params = Argon2Params{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// Limits on parameters read from a ciphertext, so that a malicious header
+// can't make decryption exhaust memory or run indefinitely.
+const (
+	maxArgon2Time   = 64
+	maxArgon2Memory = 1024 * 1024 // 1GiB
+)
+
+const (
+	passwordVersion    = 0x01
+	passwordSaltSize   = 16
+	passwordHeaderSize = 1 + 4 + 4 + 1 + passwordSaltSize
+)
+
+func (p Argon2Params) validate() error {
+	if p.Time < 1 || p.Time > maxArgon2Time {
+		return errors.New("argon2 time parameter out of range")
+	}
+	if p.Memory < 8*uint32(p.Threads) || p.Memory > maxArgon2Memory {
+		return errors.New("argon2 memory parameter out of range")
+	}
+	if p.Threads < 1 {
+		return errors.New("argon2 threads parameter out of range")
+	}
+	return nil
+}
+
+// weakerThan reports whether any of p's costs is below q's. Threads is not a
+// cost: fewer lanes doesn't make the hash any cheaper to attack.
+func (p Argon2Params) weakerThan(q Argon2Params) bool {
+	return p.Time < q.Time || p.Memory < q.Memory
+}
+
+// EncryptWithPassword encrypts data under a key derived from password using
+// Argon2id with DefaultArgon2Params.
+func EncryptWithPassword(plaintext, password []byte) ([]byte, error) {
+	return EncryptWithPasswordParams(plaintext, password, DefaultArgon2Params)
+}
+
+// EncryptWithPasswordParams is like EncryptWithPassword, but with the given
+// Argon2id parameters. The parameters are stored with the ciphertext.
+func EncryptWithPasswordParams(plaintext, password []byte, params Argon2Params) ([]byte, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, passwordHeaderSize)
+	header[0] = passwordVersion
+	binary.BigEndian.PutUint32(header[1:], params.Time)
+	binary.BigEndian.PutUint32(header[5:], params.Memory)
+	header[9] = params.Threads
+	salt := header[10:]
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := EncryptWithAD(plaintext, header, passwordKey(password, salt, params))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(header, ciphertext...), nil
+}
+
+// DecryptWithPassword decrypts data produced by EncryptWithPassword, using the
+// parameters stored in its header.
+func DecryptWithPassword(ciphertext, password []byte) ([]byte, error) {
+	params, err := parsePasswordHeader(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	header := ciphertext[:passwordHeaderSize]
+	key := passwordKey(password, header[10:], params)
+	return DecryptWithAD(ciphertext[passwordHeaderSize:], header, key)
+}
+
+// ReEncryptWithPassword decrypts data and, if it was encrypted with any cost
+// parameter below those in params, encrypts it again with a new salt. Each
+// cost is the higher of the stored and requested one, so raising Time never
+// lowers Memory or vice versa; Threads is taken from params. Otherwise it
+// returns the ciphertext unchanged. Call it whenever the password is
+// available, e.g. when a user unlocks their data, to move stored ciphertexts
+// up to the current parameters.
+func ReEncryptWithPassword(ciphertext, password []byte, params Argon2Params) ([]byte, error) {
+	plaintext, err := DecryptWithPassword(ciphertext, password)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, _ := parsePasswordHeader(ciphertext)
+	if !stored.weakerThan(params) {
+		return ciphertext, nil
+	}
+	if stored.Time > params.Time {
+		params.Time = stored.Time
+	}
+	if stored.Memory > params.Memory {
+		params.Memory = stored.Memory
+	}
+
+	return EncryptWithPasswordParams(plaintext, password, params)
+}
+
+func parsePasswordHeader(ciphertext []byte) (Argon2Params, error) {
+	if len(ciphertext) < passwordHeaderSize || ciphertext[0] != passwordVersion {
+		return Argon2Params{}, errors.New("malformed ciphertext")
+	}
+
+	params := Argon2Params{
+		Time:    binary.BigEndian.Uint32(ciphertext[1:]),
+		Memory:  binary.BigEndian.Uint32(ciphertext[5:]),
+		Threads: ciphertext[9],
+	}
+	if err := params.validate(); err != nil {
+		return Argon2Params{}, err
+	}
+	return params, nil
+}
+
+func passwordKey(password, salt []byte, params Argon2Params) *[32]byte {
+	key := &[32]byte{}
+	copy(key[:], argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, 32))
+	return key
+}
@@ -0,0 +1,144 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// Cheap parameters so the tests run quickly. Never use these for real data.
+var testArgon2Params = Argon2Params{Time: 1, Memory: 64, Threads: 1}
+
+func TestEncryptDecryptWithPassword(t *testing.T) {
+	message := []byte("Hello, world!")
+	password := []byte("correct horse battery staple")
+
+	ciphertext, err := EncryptWithPassword(message, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := DecryptWithPassword(ciphertext, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(plaintext, message) {
+		t.Errorf("plaintexts don't match")
+	}
+
+	if _, err := DecryptWithPassword(ciphertext, []byte("Tr0ub4dor&3")); err == nil {
+		t.Error("decrypted with the wrong password")
+	}
+}
+
+func TestPasswordHeaderIsAuthenticated(t *testing.T) {
+	password := []byte("correct horse battery staple")
+
+	ciphertext, err := EncryptWithPasswordParams([]byte("Hello, world!"), password, testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < passwordHeaderSize; i++ {
+		altered := append([]byte{}, ciphertext...)
+		altered[i] ^= 0x01
+		if _, err := DecryptWithPassword(altered, password); err == nil {
+			t.Errorf("decrypted with header byte %d altered", i)
+		}
+	}
+
+	// A header demanding absurd resources must be refused before running
+	// the KDF.
+	greedy := append([]byte{}, ciphertext...)
+	binary.BigEndian.PutUint32(greedy[5:], 0xffffffff)
+	if _, err := DecryptWithPassword(greedy, password); err == nil {
+		t.Error("accepted a header asking for 4TiB of memory")
+	}
+}
+
+func TestReEncryptWithPassword(t *testing.T) {
+	message := []byte("Hello, world!")
+	password := []byte("correct horse battery staple")
+
+	weak, err := EncryptWithPasswordParams(message, password, testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := testArgon2Params
+	stronger.Time = 2
+
+	upgraded, err := ReEncryptWithPassword(weak, password, stronger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := parsePasswordHeader(upgraded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params != stronger {
+		t.Errorf("re-encrypted with %+v, expected %+v", params, stronger)
+	}
+
+	plaintext, err := DecryptWithPassword(upgraded, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, message) {
+		t.Errorf("plaintexts don't match")
+	}
+
+	// Already at or above the requested parameters: nothing to do.
+	same, err := ReEncryptWithPassword(upgraded, password, testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(same, upgraded) {
+		t.Error("re-encrypted data that didn't need upgrading")
+	}
+
+	// Raising one cost while lowering another keeps the higher of each.
+	mixed := testArgon2Params
+	mixed.Time = 3
+	mixed.Memory = 32
+	mixed.Threads = 2
+	upgraded, err = ReEncryptWithPassword(same, password, mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err = parsePasswordHeader(upgraded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Argon2Params{Time: 3, Memory: 64, Threads: 2}); params != expected {
+		t.Errorf("re-encrypted with %+v, expected %+v", params, expected)
+	}
+
+	// Fewer threads alone is not a weaker setting.
+	fewerThreads := mixed
+	fewerThreads.Threads = 1
+	same, err = ReEncryptWithPassword(upgraded, password, fewerThreads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(same, upgraded) {
+		t.Error("re-encrypted data that only differed in threads")
+	}
+
+	if _, err := ReEncryptWithPassword(weak, []byte("Tr0ub4dor&3"), stronger); err == nil {
+		t.Error("re-encrypted with the wrong password")
+	}
+}
@@ -0,0 +1,436 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides password hashing with Argon2id.
+//
+// bcrypt only looks at the first 72 bytes of a password and needs little
+// memory, so it is cheap to attack with GPUs. Argon2id has neither problem.
+// Hashes are stored as PHC strings, the same format the reference
+// implementation and most other libraries use:
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+//
+// where salt and hash are unpadded standard base64. CheckPasswordHash tells
+// the formats apart by prefix, so existing bcrypt hashes keep working while
+// new ones are created with Argon2id.
+//
+// A PasswordHasher can also pepper passwords: before hashing, the password is
+// replaced by the base64 of its HMAC-SHA512/256 under a secret pepper kept
+// outside the database. A stolen database is then useless without the pepper,
+// and since the result is 44 bytes with no NULs, bcrypt sees every byte of
+// even a very long password. Peppered hashes are prefixed with the pepper's ID,
+//
+//	$pepper$<id>$2a$14$...
+//
+// so that old peppers can be kept for checking while new hashes use the
+// current one.
+package cryptopasta
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordMismatch is returned by CheckPasswordHash when the password
+// doesn't match the hash. It is the same value bcrypt returns.
+var ErrPasswordMismatch = bcrypt.ErrMismatchedHashAndPassword
+
+const (
+	argon2idPrefix   = "$argon2id$"
+	argon2idSaltSize = 16
+	argon2idKeySize  = 32
+)
+
+// HashPasswordArgon2id generates an Argon2id hash of the password using
+// DefaultArgon2Params, encoded as a PHC string.
+func HashPasswordArgon2id(password []byte) ([]byte, error) {
+	return HashPasswordArgon2idParams(password, DefaultArgon2Params)
+}
+
+// HashPasswordArgon2idParams is like HashPasswordArgon2id, but with the given
+// Argon2id parameters. The parameters are stored in the hash.
+func HashPasswordArgon2idParams(password []byte, params Argon2Params) ([]byte, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, argon2idSaltSize)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, argon2idKeySize)
+	return []byte(formatArgon2idHash(params, salt, key)), nil
+}
+
+func formatArgon2idHash(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("%sv=%d$%s$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		formatArgon2idParams(params),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func formatArgon2idParams(params Argon2Params) string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", params.Memory, params.Time, params.Threads)
+}
+
+// isArgon2idHash reports whether hash looks like an Argon2id PHC string.
+func isArgon2idHash(hash []byte) bool {
+	return bytes.HasPrefix(hash, []byte(argon2idPrefix))
+}
+
+// parseArgon2idHash decodes a PHC string produced by HashPasswordArgon2id.
+// Only the canonical encoding is accepted, and the parameters are held to the
+// same limits as for decryption, so a planted hash can't exhaust memory.
+func parseArgon2idHash(hash []byte) (params Argon2Params, salt, key []byte, err error) {
+	malformed := errors.New("malformed argon2id hash")
+
+	// "", "argon2id", "v=19", params, salt, hash
+	fields := strings.Split(string(hash), "$")
+	if len(fields) != 6 || fields[0] != "" || fields[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, malformed
+	}
+	if fields[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return Argon2Params{}, nil, nil, errors.New("unsupported argon2id version")
+	}
+
+	_, err = fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil || formatArgon2idParams(params) != fields[3] {
+		return Argon2Params{}, nil, nil, malformed
+	}
+	if err := params.validate(); err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+
+	salt, err = base64.RawStdEncoding.Strict().DecodeString(fields[4])
+	if err != nil || len(salt) < 8 {
+		return Argon2Params{}, nil, nil, malformed
+	}
+	key, err = base64.RawStdEncoding.Strict().DecodeString(fields[5])
+	if err != nil || len(key) < 16 || len(key) > 64 {
+		return Argon2Params{}, nil, nil, malformed
+	}
+
+	return params, salt, key, nil
+}
+
+// checkArgon2idHash compares an Argon2id PHC string with a possible password.
+func checkArgon2idHash(hash, password []byte) error {
+	params, salt, key, err := parseArgon2idHash(hash)
+	if err != nil {
+		return err
+	}
+
+	candidate := argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// PasswordAlgorithm selects the hash function a PasswordHasher uses.
+type PasswordAlgorithm byte
+
+const (
+	PasswordBcrypt   PasswordAlgorithm = 0x01
+	PasswordArgon2id PasswordAlgorithm = 0x02
+)
+
+// A PasswordHasher is a password hashing policy: the algorithm new hashes are
+// made with, its cost parameters, and optionally the pepper. Hashes of either
+// algorithm can be checked whatever the policy, as long as Peppers holds the
+// pepper a hash names. The zero value is not usable; start from
+// NewPasswordHasher.
+type PasswordHasher struct {
+	Algorithm  PasswordAlgorithm
+	BcryptCost int          // used when Algorithm is PasswordBcrypt
+	Argon2     Argon2Params // used when Algorithm is PasswordArgon2id
+
+	// Peppers holds every pepper that stored hashes may name, by ID. IDs
+	// must not contain '$'. Keep retired peppers here until no hashes
+	// use them.
+	Peppers map[string]*[32]byte
+	// PepperID names the pepper for new hashes. If empty, new hashes are
+	// not peppered.
+	PepperID string
+}
+
+// NewPasswordHasher returns the policy HashPassword uses: bcrypt with work
+// factor 14, and the current DefaultArgon2Params should the algorithm be
+// changed to PasswordArgon2id. The package-level functions build it afresh
+// on every call, so they always follow DefaultArgon2Params.
+func NewPasswordHasher() *PasswordHasher {
+	return &PasswordHasher{
+		Algorithm:  PasswordBcrypt,
+		BcryptCost: bcryptCost,
+		Argon2:     DefaultArgon2Params,
+	}
+}
+
+// Hash generates a hash of the password according to the policy.
+func (h *PasswordHasher) Hash(password []byte) ([]byte, error) {
+	if h.PepperID == "" {
+		return h.hash(password)
+	}
+
+	if strings.Contains(h.PepperID, "$") {
+		return nil, errors.New("pepper ID contains '$'")
+	}
+	pepper, ok := h.Peppers[h.PepperID]
+	if !ok || pepper == nil {
+		return nil, errors.New("unknown pepper ID")
+	}
+
+	hash, err := h.hash(pepperPassword(password, pepper))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(pepperPrefix+h.PepperID), hash...), nil
+}
+
+func (h *PasswordHasher) hash(password []byte) ([]byte, error) {
+	switch h.Algorithm {
+	case PasswordBcrypt:
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return nil, errors.New("bcrypt cost out of range")
+		}
+		return bcrypt.GenerateFromPassword(password, h.BcryptCost)
+	case PasswordArgon2id:
+		return HashPasswordArgon2idParams(password, h.Argon2)
+	}
+	return nil, errors.New("unknown password algorithm")
+}
+
+// Check securely compares a bcrypt or Argon2id hashed password with its
+// possible plaintext equivalent. Returns nil on success, or an error on
+// failure.
+func (h *PasswordHasher) Check(hash, password []byte) error {
+	if id, inner, ok := splitPepperedHash(hash); ok {
+		pepper, found := h.Peppers[id]
+		if !found || pepper == nil {
+			return errors.New("unknown pepper ID")
+		}
+		hash, password = inner, pepperPassword(password, pepper)
+	}
+
+	if isArgon2idHash(hash) {
+		return checkArgon2idHash(hash, password)
+	}
+	return bcrypt.CompareHashAndPassword(hash, password)
+}
+
+// NeedsRehash reports whether a stored hash falls short of the policy: it
+// was made with a different algorithm or pepper, or with any cost parameter
+// below the policy's. Hashes it can't parse also need replacing, so it
+// returns true for those.
+func (h *PasswordHasher) NeedsRehash(hash []byte) bool {
+	id, inner, peppered := splitPepperedHash(hash)
+	if id != h.PepperID {
+		return true
+	}
+	if peppered {
+		hash = inner
+	}
+
+	if isArgon2idHash(hash) {
+		if h.Algorithm != PasswordArgon2id {
+			return true
+		}
+		params, salt, key, err := parseArgon2idHash(hash)
+		if err != nil {
+			return true
+		}
+		return params.weakerThan(h.Argon2) ||
+			len(salt) < argon2idSaltSize ||
+			len(key) < argon2idKeySize
+	}
+
+	if h.Algorithm != PasswordBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost(hash)
+	if err != nil {
+		return true
+	}
+	return cost < h.BcryptCost
+}
+
+// CheckAndUpgrade checks password against hash like Check. If it matches and
+// NeedsRehash reports the hash falls short of the policy, it returns a new
+// hash made with the policy, which the caller should store in place of the
+// old one. Otherwise the new hash is nil. Call it at login, when the password
+// is at hand, to move stored hashes to the policy over time.
+func (h *PasswordHasher) CheckAndUpgrade(hash, password []byte) ([]byte, error) {
+	if err := h.Check(hash, password); err != nil {
+		return nil, err
+	}
+	if !h.NeedsRehash(hash) {
+		return nil, nil
+	}
+	return h.Hash(password)
+}
+
+// Calibration limits. bcrypt is never set below work factor 12, and Argon2id
+// memory is only lowered to meet the target down to 19MiB, the least OWASP
+// recommends.
+const (
+	minCalibratedBcryptCost = 12
+	calibrateBcryptCost     = 10
+	minCalibratedArgon2Mem  = 19 * 1024
+)
+
+// Calibrate measures hashing on this machine and sets the cost parameters for
+// the policy's algorithm so that one hash takes about target, but not less
+// than the minimums above. Run it once at deployment time, not per request:
+// it takes up to a few times target itself, and stored hashes are only as
+// strong as the machine that made them was slow.
+//
+// For bcrypt, each step in work factor doubles the time, so the factor is
+// extrapolated from a single hash at a low cost. For Argon2id, memory and
+// threads are kept and the number of passes is raised to fill target; if one
+// pass already takes longer, memory is halved until it fits.
+func (h *PasswordHasher) Calibrate(target time.Duration) error {
+	switch h.Algorithm {
+	case PasswordBcrypt:
+		elapsed, err := timeHash(func() error {
+			_, err := bcrypt.GenerateFromPassword([]byte("calibrate"), calibrateBcryptCost)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		cost := calibrateBcryptCost
+		for elapsed*2 <= target && cost < bcrypt.MaxCost {
+			elapsed *= 2
+			cost++
+		}
+		if cost < minCalibratedBcryptCost {
+			cost = minCalibratedBcryptCost
+		}
+		h.BcryptCost = cost
+		return nil
+
+	case PasswordArgon2id:
+		params := h.Argon2
+		params.Time = 1
+		if err := params.validate(); err != nil {
+			return err
+		}
+
+		for {
+			elapsed, err := timeHash(func() error {
+				_, err := HashPasswordArgon2idParams([]byte("calibrate"), params)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			if elapsed > target && params.Memory/2 >= minCalibratedArgon2Mem {
+				params.Memory /= 2
+				continue
+			}
+
+			if elapsed > 0 && target/elapsed > 1 {
+				// Clamp before converting, or a huge ratio wraps around.
+				ratio := target / elapsed
+				if ratio > maxArgon2Time {
+					ratio = maxArgon2Time
+				}
+				params.Time = uint32(ratio)
+			}
+			h.Argon2 = params
+			return nil
+		}
+	}
+	return errors.New("unknown password algorithm")
+}
+
+// timeHash runs f and reports how long it took. It is replaced in tests to
+// simulate hardware of a given speed.
+var timeHash = func(f func() error) (time.Duration, error) {
+	start := time.Now()
+	err := f()
+	return time.Since(start), err
+}
+
+// forHash returns a copy of the policy using the algorithm hash was made
+// with, so that checking it against the policy never changes its algorithm.
+func (h *PasswordHasher) forHash(hash []byte) *PasswordHasher {
+	p := *h
+	if _, inner, ok := splitPepperedHash(hash); ok {
+		hash = inner
+	}
+	if isArgon2idHash(hash) {
+		p.Algorithm = PasswordArgon2id
+	} else {
+		p.Algorithm = PasswordBcrypt
+	}
+	return &p
+}
+
+const pepperPrefix = "$pepper$"
+
+// pepperPassword replaces a password with the base64 of its HMAC under the
+// pepper.
+func pepperPassword(password []byte, pepper *[32]byte) []byte {
+	mac := GenerateHMAC(password, pepper)
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(mac)))
+	base64.StdEncoding.Encode(out, mac)
+	return out
+}
+
+// splitPepperedHash splits "$pepper$<id>$..." into the pepper ID and the
+// inner hash, which keeps its leading '$'. ok is false if hash isn't
+// peppered.
+func splitPepperedHash(hash []byte) (id string, inner []byte, ok bool) {
+	if !bytes.HasPrefix(hash, []byte(pepperPrefix)) {
+		return "", nil, false
+	}
+	rest := hash[len(pepperPrefix):]
+	end := bytes.IndexByte(rest, '$')
+	if end < 1 {
+		return "", nil, false
+	}
+	return string(rest[:end]), rest[end:], true
+}
+
+// NeedsRehash reports whether a stored password hash was made with weaker
+// settings than the current ones: a bcrypt work factor below 14, or any
+// Argon2id parameter below DefaultArgon2Params. Hashes it can't parse also
+// need replacing, so it returns true for those.
+func NeedsRehash(hash []byte) bool {
+	return NewPasswordHasher().forHash(hash).NeedsRehash(hash)
+}
+
+// CheckAndUpgrade checks password against hash like CheckPasswordHash. If it
+// matches and NeedsRehash reports the hash is out of date, it returns a new
+// hash of the same kind made with the current settings, which the caller
+// should store in place of the old one. Otherwise the new hash is nil. Call it
+// at login, when the password is at hand, to strengthen stored hashes over
+// time.
+func CheckAndUpgrade(hash, password []byte) ([]byte, error) {
+	return NewPasswordHasher().forHash(hash).CheckAndUpgrade(hash, password)
+}
@@ -0,0 +1,479 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var phcPattern = regexp.MustCompile(`^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`)
+
+func TestHashPasswordArgon2id(t *testing.T) {
+	password := []byte("correct horse battery staple")
+
+	hash, err := HashPasswordArgon2idParams(password, testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !phcPattern.Match(hash) {
+		t.Errorf("hash is not a PHC string: %s", hash)
+	}
+
+	if err := CheckPasswordHash(hash, password); err != nil {
+		t.Error(err)
+	}
+	if err := CheckPasswordHash(hash, []byte("Correct horse battery staple")); err != ErrPasswordMismatch {
+		t.Errorf("expected ErrPasswordMismatch, got %v", err)
+	}
+
+	// Unlike bcrypt, every byte of a long password counts.
+	long := bytes.Repeat([]byte("a"), 100)
+	hash, err = HashPasswordArgon2idParams(long, testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPasswordHash(hash, append(long[:99:99], 'b')); err != ErrPasswordMismatch {
+		t.Errorf("expected ErrPasswordMismatch for long password, got %v", err)
+	}
+
+	hash2, err := HashPasswordArgon2idParams(password, testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(hash, hash2) {
+		t.Error("two hashes of the same password are identical")
+	}
+}
+
+func TestCheckPasswordHashMixed(t *testing.T) {
+	password := []byte("password")
+
+	bcryptHash, err := bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHash, err := HashPasswordArgon2idParams(password, testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, hash := range [][]byte{bcryptHash, argonHash} {
+		if err := CheckPasswordHash(hash, password); err != nil {
+			t.Errorf("%s: %v", hash, err)
+		}
+		if err := CheckPasswordHash(hash, []byte("wrong")); err != ErrPasswordMismatch {
+			t.Errorf("%s: expected ErrPasswordMismatch, got %v", hash, err)
+		}
+	}
+}
+
+func TestCheckPasswordHashMalformed(t *testing.T) {
+	password := []byte("password")
+
+	hash, err := HashPasswordArgon2idParams(password, testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := string(hash)
+
+	malformedTests := []struct {
+		name string
+		hash string
+	}{
+		{"wrong version", strings.Replace(good, "v=19", "v=16", 1)},
+		{"missing version", strings.Replace(good, "$v=19", "", 1)},
+		{"reordered params", strings.Replace(good, "m=64,t=1,p=1", "t=1,m=64,p=1", 1)},
+		{"padded param", strings.Replace(good, "m=64", "m=064", 1)},
+		{"signed param", strings.Replace(good, "t=1", "t=+1", 1)},
+		{"zero time", strings.Replace(good, "t=1", "t=0", 1)},
+		{"huge memory", strings.Replace(good, "m=64", "m=4294967295", 1)},
+		{"padded salt", good[:strings.LastIndex(good, "$")] + "==" + good[strings.LastIndex(good, "$"):]},
+		{"short hash", good[:len(good)-30]},
+		{"trailing field", good + "$"},
+		{"argon2i", strings.Replace(good, "argon2id", "argon2i", 1)},
+	}
+
+	for _, tt := range malformedTests {
+		if err := CheckPasswordHash([]byte(tt.hash), password); err == nil {
+			t.Errorf("%s: accepted %s", tt.name, tt.hash)
+		}
+	}
+}
+
+func TestArgon2idParamsRoundTrip(t *testing.T) {
+	params := Argon2Params{Time: 2, Memory: 128, Threads: 2}
+	salt := bytes.Repeat([]byte{0x01}, argon2idSaltSize)
+	key := bytes.Repeat([]byte{0x02}, argon2idKeySize)
+
+	hash := formatArgon2idHash(params, salt, key)
+	if hash != "$argon2id$v=19$m=128,t=2,p=2$AQEBAQEBAQEBAQEBAQEBAQ$AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI" {
+		t.Errorf("unexpected encoding %s", hash)
+	}
+
+	parsedParams, parsedSalt, parsedKey, err := parseArgon2idHash([]byte(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsedParams != params || !bytes.Equal(parsedSalt, salt) || !bytes.Equal(parsedKey, key) {
+		t.Error("parsed hash does not match")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weakBcrypt, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weakArgon2id, err := HashPasswordArgon2idParams([]byte("password"), testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := make([]byte, argon2idSaltSize)
+	key := make([]byte, argon2idKeySize)
+	stronger := DefaultArgon2Params
+	stronger.Time++
+
+	rehashTests := []struct {
+		name  string
+		hash  []byte
+		needs bool
+	}{
+		{"bcrypt at current cost", []byte("$2a$14$uALAQb/Lwl59oHVbuUa5m.xEFmQBc9ME/IiSgJK/VHtNJJXASCDoS"), false},
+		{"bcrypt above current cost", []byte("$2a$15$uALAQb/Lwl59oHVbuUa5m.xEFmQBc9ME/IiSgJK/VHtNJJXASCDoS"), false},
+		{"bcrypt below current cost", weakBcrypt, true},
+		{"argon2id at defaults", []byte(formatArgon2idHash(DefaultArgon2Params, salt, key)), false},
+		{"argon2id above defaults", []byte(formatArgon2idHash(stronger, salt, key)), false},
+		{"argon2id below defaults", weakArgon2id, true},
+		{"argon2id short salt", []byte(formatArgon2idHash(DefaultArgon2Params, salt[:8], key)), true},
+		{"malformed argon2id", []byte("$argon2id$v=19$"), true},
+		{"unknown format", []byte("5f4dcc3b5aa765d61d8327deb882cf99"), true},
+		{"empty", nil, true},
+	}
+
+	for _, tt := range rehashTests {
+		if NeedsRehash(tt.hash) != tt.needs {
+			t.Errorf("%s: expected NeedsRehash %v", tt.name, tt.needs)
+		}
+	}
+}
+
+func TestCheckAndUpgrade(t *testing.T) {
+	password := []byte("password")
+
+	weakBcrypt, err := bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weakArgon2id, err := HashPasswordArgon2idParams(password, testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, hash := range [][]byte{weakBcrypt, weakArgon2id} {
+		if upgraded, err := CheckAndUpgrade(hash, []byte("wrong")); err != ErrPasswordMismatch || upgraded != nil {
+			t.Errorf("%s: wrong password gave %q, %v", hash, upgraded, err)
+		}
+
+		upgraded, err := CheckAndUpgrade(hash, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if upgraded == nil {
+			t.Fatalf("%s: no upgrade", hash)
+		}
+		if isArgon2idHash(upgraded) != isArgon2idHash(hash) {
+			t.Errorf("%s: upgrade changed algorithm to %s", hash, upgraded)
+		}
+		if NeedsRehash(upgraded) {
+			t.Errorf("%s: upgraded hash still needs rehash", hash)
+		}
+		if err := CheckPasswordHash(upgraded, password); err != nil {
+			t.Error(err)
+		}
+
+		// An up-to-date hash is left alone.
+		again, err := CheckAndUpgrade(upgraded, password)
+		if err != nil || again != nil {
+			t.Errorf("%s: current hash gave %q, %v", upgraded, again, err)
+		}
+	}
+}
+
+func TestDefaultArgon2ParamsChange(t *testing.T) {
+	defer func(params Argon2Params) { DefaultArgon2Params = params }(DefaultArgon2Params)
+	password := []byte("password")
+
+	// Raising the defaults flags hashes that met the old ones.
+	current := []byte(formatArgon2idHash(DefaultArgon2Params, make([]byte, 16), make([]byte, 32)))
+	DefaultArgon2Params.Time++
+	if !NeedsRehash(current) {
+		t.Error("hash at the old defaults doesn't need rehash after raising them")
+	}
+
+	// Upgrades use the configured defaults, not the ones at startup.
+	DefaultArgon2Params = Argon2Params{Time: 2, Memory: 128, Threads: 1}
+	weak, err := HashPasswordArgon2idParams(password, testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upgraded, err := CheckAndUpgrade(weak, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, _, _, err := parseArgon2idHash(upgraded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params != DefaultArgon2Params {
+		t.Errorf("upgraded to %+v, expected %+v", params, DefaultArgon2Params)
+	}
+	if NeedsRehash(upgraded) {
+		t.Error("upgraded hash still needs rehash")
+	}
+	if NewPasswordHasher().Argon2 != DefaultArgon2Params {
+		t.Error("NewPasswordHasher ignores DefaultArgon2Params")
+	}
+}
+
+func TestPasswordHasher(t *testing.T) {
+	password := []byte("password")
+
+	bcryptHasher := NewPasswordHasher()
+	bcryptHasher.BcryptCost = bcrypt.MinCost
+	argonHasher := NewPasswordHasher()
+	argonHasher.Algorithm = PasswordArgon2id
+	argonHasher.Argon2 = testArgon2Params
+
+	bcryptHash, err := bcryptHasher.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, _ := bcrypt.Cost(bcryptHash); cost != bcrypt.MinCost {
+		t.Errorf("hash has cost %d", cost)
+	}
+	argonHash, err := argonHasher.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !phcPattern.Match(argonHash) {
+		t.Errorf("hash is not a PHC string: %s", argonHash)
+	}
+
+	// Either policy checks either kind of hash.
+	for _, h := range []*PasswordHasher{bcryptHasher, argonHasher} {
+		for _, hash := range [][]byte{bcryptHash, argonHash} {
+			if err := h.Check(hash, password); err != nil {
+				t.Errorf("%s: %v", hash, err)
+			}
+		}
+	}
+
+	// Hashes of the other algorithm need rehashing; the policy's own don't.
+	if bcryptHasher.NeedsRehash(bcryptHash) || !bcryptHasher.NeedsRehash(argonHash) {
+		t.Error("bcrypt policy rehash decisions are wrong")
+	}
+	if argonHasher.NeedsRehash(argonHash) || !argonHasher.NeedsRehash(bcryptHash) {
+		t.Error("argon2id policy rehash decisions are wrong")
+	}
+
+	// Migrating a bcrypt hash to Argon2id at login.
+	upgraded, err := argonHasher.CheckAndUpgrade(bcryptHash, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isArgon2idHash(upgraded) {
+		t.Errorf("bcrypt hash was not migrated: %s", upgraded)
+	}
+	if err := CheckPasswordHash(upgraded, password); err != nil {
+		t.Error(err)
+	}
+
+	// The package-level functions never change a hash's algorithm.
+	if NeedsRehash([]byte(formatArgon2idHash(DefaultArgon2Params, make([]byte, 16), make([]byte, 32)))) {
+		t.Error("default policy wants to rehash a current argon2id hash")
+	}
+
+	bad := []*PasswordHasher{
+		{},
+		{Algorithm: PasswordBcrypt, BcryptCost: 3},
+		{Algorithm: PasswordBcrypt, BcryptCost: 32},
+		{Algorithm: PasswordArgon2id},
+	}
+	for _, h := range bad {
+		if _, err := h.Hash(password); err == nil {
+			t.Errorf("hashed with bad policy %+v", h)
+		}
+	}
+}
+
+func TestPasswordHasherCalibrate(t *testing.T) {
+	h := NewPasswordHasher()
+	if err := h.Calibrate(time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	if h.BcryptCost != minCalibratedBcryptCost {
+		t.Errorf("bcrypt cost %d for tiny target, expected floor %d", h.BcryptCost, minCalibratedBcryptCost)
+	}
+
+	if err := h.Calibrate(1000 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if h.BcryptCost != bcrypt.MaxCost {
+		t.Errorf("bcrypt cost %d for huge target, expected %d", h.BcryptCost, bcrypt.MaxCost)
+	}
+
+	h.Algorithm = PasswordArgon2id
+	h.Argon2 = Argon2Params{Time: 5, Memory: 64, Threads: 1}
+	if err := h.Calibrate(time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	if h.Argon2 != (Argon2Params{Time: 1, Memory: 64, Threads: 1}) {
+		t.Errorf("unexpected params %+v for tiny target", h.Argon2)
+	}
+
+	if err := h.Calibrate(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if h.Argon2 != (Argon2Params{Time: maxArgon2Time, Memory: 64, Threads: 1}) {
+		t.Errorf("unexpected params %+v for huge target", h.Argon2)
+	}
+
+	// A ratio of 2^32 or more is clamped, not wrapped to zero.
+	realTimeHash := timeHash
+	timeHash = func(f func() error) (time.Duration, error) {
+		return time.Nanosecond, f()
+	}
+	err := h.Calibrate(1 << 32)
+	timeHash = realTimeHash
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Argon2 != (Argon2Params{Time: maxArgon2Time, Memory: 64, Threads: 1}) {
+		t.Errorf("unexpected params %+v when the ratio overflows", h.Argon2)
+	}
+
+	// Memory is traded away before the target is missed, but not below
+	// the floor.
+	h.Argon2 = Argon2Params{Time: 1, Memory: 4 * minCalibratedArgon2Mem, Threads: 1}
+	if err := h.Calibrate(time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	if h.Argon2.Memory != minCalibratedArgon2Mem || h.Argon2.Time != 1 {
+		t.Errorf("unexpected params %+v for tiny target", h.Argon2)
+	}
+
+	h.Algorithm = 0
+	if err := h.Calibrate(time.Second); err == nil {
+		t.Error("calibrated an unknown algorithm")
+	}
+}
+
+func TestPasswordHasherPepper(t *testing.T) {
+	password := []byte("password")
+	pepper1, pepper2 := NewEncryptionKey(), NewEncryptionKey()
+
+	h := NewPasswordHasher()
+	h.BcryptCost = bcrypt.MinCost
+	h.Peppers = map[string]*[32]byte{"2016-01": pepper1}
+	h.PepperID = "2016-01"
+
+	hash, err := h.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(hash, []byte("$pepper$2016-01$2a$04$")) {
+		t.Errorf("unexpected peppered hash %s", hash)
+	}
+	if err := h.Check(hash, password); err != nil {
+		t.Error(err)
+	}
+	if err := h.Check(hash, []byte("wrong")); err != ErrPasswordMismatch {
+		t.Errorf("expected ErrPasswordMismatch, got %v", err)
+	}
+	if h.NeedsRehash(hash) {
+		t.Error("current peppered hash needs rehash")
+	}
+
+	// Without the pepper, the hash is useless.
+	if err := CheckPasswordHash(hash, password); err == nil {
+		t.Error("peppered hash checked without pepper")
+	}
+	if err := CheckPasswordHash(hash[len("$pepper$2016-01"):], password); err == nil {
+		t.Error("inner hash checked without pepper")
+	}
+
+	// bcrypt alone would ignore everything after 72 bytes.
+	long := bytes.Repeat([]byte("a"), 100)
+	hash, err = h.Hash(long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Check(hash, append(long[:99:99], 'b')); err != ErrPasswordMismatch {
+		t.Errorf("expected ErrPasswordMismatch for long password, got %v", err)
+	}
+
+	// Rotation: old hashes still check and are upgraded to the new pepper.
+	old, err := h.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpeppered, err := bcrypt.GenerateFromPassword(password, bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Peppers["2016-02"] = pepper2
+	h.PepperID = "2016-02"
+
+	for _, stored := range [][]byte{old, unpeppered} {
+		if !h.NeedsRehash(stored) {
+			t.Errorf("%s: hash with old pepper doesn't need rehash", stored)
+		}
+		upgraded, err := h.CheckAndUpgrade(stored, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(upgraded, []byte("$pepper$2016-02$")) {
+			t.Errorf("%s: upgraded to %s", stored, upgraded)
+		}
+	}
+
+	delete(h.Peppers, "2016-01")
+	if err := h.Check(old, password); err == nil {
+		t.Error("checked hash with retired pepper")
+	}
+
+	bad := []*PasswordHasher{
+		{Algorithm: PasswordBcrypt, BcryptCost: bcrypt.MinCost, PepperID: "missing"},
+		{Algorithm: PasswordBcrypt, BcryptCost: bcrypt.MinCost, PepperID: "a$b", Peppers: map[string]*[32]byte{"a$b": pepper1}},
+	}
+	for _, h := range bad {
+		if _, err := h.Hash(password); err == nil {
+			t.Errorf("hashed with bad pepper policy %+v", h)
+		}
+	}
+}
+
+func BenchmarkArgon2id(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, err := HashPasswordArgon2id([]byte("thisisareallybadpassword"))
+		if err != nil {
+			b.Error(err)
+			break
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides checks that a new password is worth hashing.
+//
+// No work factor saves "password1". Before storing a new password, check that
+// it is long enough, not trivially guessable, and not in a list of passwords
+// from known breaches. Guessability is estimated in the spirit of zxcvbn: the
+// cheaper of brute force, with discounts for repeated and sequential
+// characters, and a dictionary attack on common passwords with leetspeak and
+// a trailing run of digits or symbols. It is far cruder than zxcvbn, so treat
+// the number as a floor for rejecting the worst passwords, not a strength
+// meter.
+//
+// The breach check never sends anything over the network. It reads a local
+// copy of the Have I Been Pwned Pwned Passwords range files, which keep the
+// k-anonymity layout of its range API: one file per five-hex-character SHA-1
+// prefix, named by the prefix in upper case with or without a .txt
+// extension, each line holding the remaining 35 hex characters, a colon, and
+// the number of times the password was seen.
+package cryptopasta
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicy sets the requirements for new passwords.
+type PasswordPolicy struct {
+	MinLength  int     // in characters, not bytes
+	MinEntropy float64 // in bits, as estimated by EstimatePasswordEntropy
+	BreachDir  string  // directory of breach range files; empty skips the check
+}
+
+// DefaultPasswordPolicy requires eight characters, as NIST SP 800-63B does,
+// and an estimated 30 bits of entropy. Set BreachDir to enable the breach
+// check.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:  8,
+	MinEntropy: 30,
+}
+
+// PasswordProblem is a reason PasswordPolicy.Check rejected a password.
+type PasswordProblem byte
+
+const (
+	PasswordTooShort     PasswordProblem = 0x01
+	PasswordTooGuessable PasswordProblem = 0x02
+	PasswordBreached     PasswordProblem = 0x03
+)
+
+func (p PasswordProblem) String() string {
+	switch p {
+	case PasswordTooShort:
+		return "password is too short"
+	case PasswordTooGuessable:
+		return "password is too easy to guess"
+	case PasswordBreached:
+		return "password has appeared in a data breach"
+	}
+	return "unknown password problem"
+}
+
+// Check returns every requirement the password fails, or nil if it is
+// acceptable. The error is only for failing to read the breach files; a
+// missing range file means no breached password has that prefix.
+func (p *PasswordPolicy) Check(password []byte) ([]PasswordProblem, error) {
+	var problems []PasswordProblem
+
+	if utf8.RuneCount(password) < p.MinLength {
+		problems = append(problems, PasswordTooShort)
+	}
+	if EstimatePasswordEntropy(password) < p.MinEntropy {
+		problems = append(problems, PasswordTooGuessable)
+	}
+	if p.BreachDir != "" {
+		count, err := breachCount(p.BreachDir, password)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			problems = append(problems, PasswordBreached)
+		}
+	}
+
+	return problems, nil
+}
+
+// breachCount looks up how many times password appears in the range files
+// in dir.
+func breachCount(dir string, password []byte) (int, error) {
+	digest := sha1.Sum(password)
+	hexDigest := strings.ToUpper(hex.EncodeToString(digest[:]))
+	prefix, suffix := hexDigest[:5], hexDigest[5:]
+
+	f, err := os.Open(filepath.Join(dir, prefix))
+	if os.IsNotExist(err) {
+		f, err = os.Open(filepath.Join(dir, prefix+".txt"))
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 || !strings.EqualFold(line[:colon], suffix) {
+			continue
+		}
+		// Padding entries added to hide the true size of a range have
+		// a count of zero.
+		return strconv.Atoi(line[colon+1:])
+	}
+	return 0, scanner.Err()
+}
+
+// commonPasswords are tried in order by the dictionary estimate, so the
+// position of a match stands in for its number of guesses.
+var commonPasswords = []string{
+	"password", "123456", "qwerty", "letmein", "abc123", "monkey", "dragon",
+	"111111", "iloveyou", "admin", "welcome", "football", "baseball",
+	"master", "sunshine", "princess", "shadow", "superman", "trustno",
+	"hello", "freedom", "whatever", "secret", "login", "starwars", "summer",
+	"winter", "spring", "autumn", "qwertyuiop", "asdfgh", "zxcvbn",
+	"passw", "pass", "changeme", "default", "access", "mustang", "michael",
+	"charlie", "jordan", "hunter", "ranger", "batman", "soccer", "hockey",
+}
+
+var leetSubstitutions = strings.NewReplacer(
+	"0", "o", "1", "l", "3", "e", "4", "a", "@", "a", "$", "s", "5", "s", "7", "t",
+)
+
+// EstimatePasswordEntropy returns a rough estimate, in bits, of how hard the
+// password is to guess: the base-2 logarithm of the number of guesses an
+// attacker would need.
+func EstimatePasswordEntropy(password []byte) float64 {
+	s := string(password)
+	return math.Min(bruteForceEntropy(s), dictionaryEntropy(s))
+}
+
+// bruteForceEntropy charges each character the size of the character set in
+// use, except that repeating or stepping by one from the previous character
+// is nearly free, as in "aaaa" or "1234".
+func bruteForceEntropy(s string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	charBits := math.Log2(float64(pool))
+	var bits float64
+	var prev rune = -1
+	for _, r := range s {
+		switch {
+		case r == prev:
+			bits++
+		case r == prev+1 || r == prev-1:
+			bits += 2
+		default:
+			bits += charBits
+		}
+		prev = r
+	}
+	return bits
+}
+
+// dictionaryEntropy estimates the cost of guessing the password as a common
+// password, possibly capitalized or in leetspeak, followed by a short run of
+// digits and symbols. Anything else is returned as infinitely expensive.
+func dictionaryEntropy(s string) float64 {
+	base := strings.TrimRightFunc(s, func(r rune) bool {
+		return r < utf8.RuneSelf && !unicode.IsLetter(r)
+	})
+	decoration := s[len(base):]
+	if base == "" {
+		// all digits and symbols, e.g. "123456"
+		base, decoration = s, ""
+	}
+
+	lowerBase := strings.ToLower(base)
+	var bits float64
+	if base != lowerBase {
+		bits++ // which letters are capitalized; usually just the first
+	}
+	rank := commonPasswordRank(lowerBase)
+	if rank < 0 {
+		rank = commonPasswordRank(leetSubstitutions.Replace(lowerBase))
+		bits++ // which letters were substituted
+	}
+	if rank >= 0 {
+		return bits + math.Log2(float64(rank+1)) + bruteForceEntropy(decoration)
+	}
+
+	return math.Inf(1)
+}
+
+func commonPasswordRank(word string) int {
+	for rank, common := range commonPasswords {
+		if word == common {
+			return rank
+		}
+	}
+	return -1
+}
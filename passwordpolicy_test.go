@@ -0,0 +1,102 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testdata/breached stands in for the breach corpus. It has range files for
+// "password" (5BAA6) and "password1" (E38AD), each with made-up neighbors,
+// and for "Tr0ub4dor&3" (87457) with only a zero-count padding entry.
+func TestPasswordPolicy(t *testing.T) {
+	policy := DefaultPasswordPolicy
+	policy.BreachDir = "testdata/breached"
+
+	policyTests := []struct {
+		password string
+		problems []PasswordProblem
+	}{
+		{"password1", []PasswordProblem{PasswordTooGuessable, PasswordBreached}},
+		{"password", []PasswordProblem{PasswordTooGuessable, PasswordBreached}},
+		{"P@ssw0rd!", []PasswordProblem{PasswordTooGuessable}},
+		{"Summer2016", []PasswordProblem{PasswordTooGuessable}},
+		{"aaaaaaaaaaaa", []PasswordProblem{PasswordTooGuessable}},
+		{"12345678", []PasswordProblem{PasswordTooGuessable}},
+		{"x7#Kq", []PasswordProblem{PasswordTooShort}},
+		{"abc", []PasswordProblem{PasswordTooShort, PasswordTooGuessable}},
+		{"Tr0ub4dor&3", nil},
+		{"correct horse battery staple", nil},
+		{"mot de passe très sûr", nil},
+	}
+
+	for _, tt := range policyTests {
+		problems, err := policy.Check([]byte(tt.password))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(problems, tt.problems) {
+			t.Errorf("%q: got %v, expected %v (entropy %.1f)",
+				tt.password, problems, tt.problems, EstimatePasswordEntropy([]byte(tt.password)))
+		}
+	}
+}
+
+func TestPasswordPolicyBreachFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cryptopasta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	policy := PasswordPolicy{BreachDir: dir}
+
+	// A prefix with no file has no breached passwords.
+	if problems, err := policy.Check([]byte("password1")); err != nil || problems != nil {
+		t.Errorf("empty corpus gave %v, %v", problems, err)
+	}
+
+	// Files may carry a .txt extension and lower-case hex.
+	err = ioutil.WriteFile(filepath.Join(dir, "E38AD.txt"),
+		[]byte("214943daad1d64c102faec29de4afe9da3d:3\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, err := policy.Check([]byte("password1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(problems, []PasswordProblem{PasswordBreached}) {
+		t.Errorf("got %v, expected breached", problems)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "5BAA6"),
+		[]byte("1E4C9B93F3F0682250B6CF8331B7EE68FD8:lots\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.Check([]byte("password")); err == nil {
+		t.Error("malformed count was accepted")
+	}
+}
+
+func TestPasswordProblemString(t *testing.T) {
+	for _, p := range []PasswordProblem{PasswordTooShort, PasswordTooGuessable, PasswordBreached} {
+		if p.String() == PasswordProblem(0).String() {
+			t.Errorf("problem %d has no description", p)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+//go:build go1.24
+
+// cryptopasta - basic cryptography examples
+//
+// Written in 2015 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides deterministic ECDSA signatures as described in RFC 6979.
+package cryptopasta
+
+import (
+	"crypto/ecdsa"
+)
+
+// SignDeterministic signs arbitrary data using ECDSA with the nonce derived
+// from the key and message as described in RFC 6979, rather than read from
+// rand.Reader. The same key and data always produce the same signature, which
+// stays safe on hosts with a weak random number generator and is reproducible
+// in tests. Output is the same r||s format Verify accepts.
+//
+// It needs Go 1.24 or later, where a nil source of randomness selects RFC
+// 6979 nonces; with older toolchains it is not built at all, rather than
+// reading from a nil io.Reader at run time.
+func SignDeterministic(data []byte, privkey *ecdsa.PrivateKey) ([]byte, error) {
+	// hash message
+	hash := hashForCurve(privkey.Curve)
+	digest, err := hashMessage(data, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// a nil source of randomness selects RFC 6979 nonces
+	der, err := privkey.Sign(nil, digest, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return SignatureFromASN1(der, privkey.Curve)
+}
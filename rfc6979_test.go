@@ -0,0 +1,97 @@
+//go:build go1.24
+
+// cryptopasta - basic cryptography examples
+//
+// Written in 2015 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// https://tools.ietf.org/html/rfc6979#appendix-A.2.5 through A.2.7
+var rfc6979Tests = []struct {
+	curve   elliptic.Curve
+	key     string
+	message string
+	r       string
+	s       string
+}{
+	{
+		curve:   elliptic.P256(),
+		key:     "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721",
+		message: "sample",
+		r:       "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+		s:       "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+	},
+	{
+		curve:   elliptic.P256(),
+		key:     "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721",
+		message: "test",
+		r:       "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+		s:       "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+	},
+	{
+		curve:   elliptic.P384(),
+		key:     "6B9D3DAD2E1B8C1C05B19875B6659F4DE23C3B667BF297BA9AA47740787137D896D5724E4C70A825F872C9EA60D2EDF5",
+		message: "sample",
+		r:       "94EDBB92A5ECB8AAD4736E56C691916B3F88140666CE9FA73D64C4EA95AD133C81A648152E44ACF96E36DD1E80FABE46",
+		s:       "99EF4AEB15F178CEA1FE40DB2603138F130E740A19624526203B6351D0A3A94FA329C145786E679E7B82C71A38628AC8",
+	},
+	{
+		curve:   elliptic.P384(),
+		key:     "6B9D3DAD2E1B8C1C05B19875B6659F4DE23C3B667BF297BA9AA47740787137D896D5724E4C70A825F872C9EA60D2EDF5",
+		message: "test",
+		r:       "8203B63D3C853E8D77227FB377BCF7B7B772E97892A80F36AB775D509D7A5FEB0542A7F0812998DA8F1DD3CA3CF023DB",
+		s:       "DDD0760448D42D8A43AF45AF836FCE4DE8BE06B485E9B61B827C2F13173923E06A739F040649A667BF3B828246BAA5A5",
+	},
+	{
+		curve:   elliptic.P521(),
+		key:     "0FAD06DAA62BA3B25D2FB40133DA757205DE67F5BB0018FEE8C86E1B68C7E75CAA896EB32F1F47C70855836A6D16FCC1466F6D8FBEC67DB89EC0C08B0E996B83538",
+		message: "sample",
+		r:       "00C328FAFCBD79DD77850370C46325D987CB525569FB63C5D3BC53950E6D4C5F174E25A1EE9017B5D450606ADD152B534931D7D4E8455CC91F9B15BF05EC36E377FA",
+		s:       "00617CCE7CF5064806C467F678D3B4080D6F1CC50AF26CA209417308281B68AF282623EAA63E5B5C0723D8B8C37FF0777B1A20F8CCB1DCCC43997F1EE0E44DA4A67A",
+	},
+}
+
+// rfc6979Key builds the private key with scalar d on curve.
+func rfc6979Key(curve elliptic.Curve, d string) *ecdsa.PrivateKey {
+	key := &ecdsa.PrivateKey{D: new(big.Int)}
+	key.D.SetString(d, 16)
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(key.D.Bytes())
+	return key
+}
+
+func TestSignDeterministic(t *testing.T) {
+	for idx, tt := range rfc6979Tests {
+		key := rfc6979Key(tt.curve, tt.key)
+		expected, _ := hex.DecodeString(tt.r + tt.s)
+
+		signature, err := SignDeterministic([]byte(tt.message), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(signature, expected) {
+			t.Errorf("test %d generated unexpected signature %x", idx, signature)
+		}
+
+		if !Verify([]byte(tt.message), signature, &key.PublicKey) {
+			t.Errorf("test %d signature was not correct", idx)
+		}
+	}
+}
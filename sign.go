@@ -17,22 +17,38 @@
 // recommendation.
 //
 // Asymmetric Signature: ECDSA using P256 and SHA256
+// P-384 and P-521 keys are also accepted, and use SHA-384 and SHA-512
+// respectively to match the ES384 and ES512 algorithms in RFC7518.
 // ECDSA is the best compromise between cryptographic concerns and support for
 // our internal use cases (e.g. RFC7518). The Go standard library
 // implementation has some protection against entropy problems, but is not
 // deterministic. See
 // https://github.com/golang/go/commit/8d7bf2291b095d3a2ecaa2609e1101be46d80deb
+// SignDeterministic (Go 1.24 and later) uses RFC 6979 nonces instead, for
+// hosts where even that protection isn't enough.
+//
+// Asymmetric Signature: Ed25519
+// Ed25519 signatures are deterministic, fast, and a fixed 64 bytes with no
+// encoding choices to get wrong. Prefer them for new services that don't
+// need to interoperate with ECDSA-only software.
 package cryptopasta
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/hmac"
 	"crypto/rand"
-	"crypto/sha256"
+	_ "crypto/sha256" // registers crypto.SHA256
 	"crypto/sha512"
+	"errors"
+	"hash"
 	"io"
 	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
 )
 
 // NewHMACKey generates a random 256-bit secret key for HMAC use.
@@ -66,40 +82,412 @@ func NewSigningKey() (*ecdsa.PrivateKey, error) {
 	return key, err
 }
 
-// Sign signs arbitrary data using ECDSA.
+// Sign signs arbitrary data using ECDSA. The message digest is chosen to
+// match the curve as in RFC 7518: SHA-256 for P-256, SHA-384 for P-384 and
+// SHA-512 for P-521.
 func Sign(data []byte, privkey *ecdsa.PrivateKey) ([]byte, error) {
+	return SignWithHash(data, privkey, hashForCurve(privkey.Curve))
+}
+
+// SignWithHash signs arbitrary data using ECDSA with the given message
+// digest. Use it with crypto.SHA256 to interoperate with signatures made on
+// P-384 or P-521 keys before Sign chose the digest by curve.
+func SignWithHash(data []byte, privkey *ecdsa.PrivateKey, hash crypto.Hash) ([]byte, error) {
 	// hash message
-	digest := sha256.Sum256(data)
+	digest, err := hashMessage(data, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return signDigest(digest, privkey)
+}
 
+func signDigest(digest []byte, privkey *ecdsa.PrivateKey) ([]byte, error) {
 	// sign the hash
-	r, s, err := ecdsa.Sign(rand.Reader, privkey, digest[:])
+	r, s, err := ecdsa.Sign(rand.Reader, privkey, digest)
 	if err != nil {
 		return nil, err
 	}
 
-	// encode the signature {R, S}
-	// big.Int.Bytes() will need padding in the case of leading zero bytes
-	params := privkey.Curve.Params()
-	curveOrderByteSize := params.P.BitLen() / 8
-	rBytes, sBytes := r.Bytes(), s.Bytes()
-	signature := make([]byte, curveOrderByteSize*2)
-	copy(signature[curveOrderByteSize-len(rBytes):], rBytes)
-	copy(signature[curveOrderByteSize*2-len(sBytes):], sBytes)
+	return encodeSignature(r, s, privkey.Curve), nil
+}
+
+// SignLowS signs arbitrary data like Sign, but always produces the signature
+// with s in the lower half of the curve order, as VerifyLowS requires.
+func SignLowS(data []byte, privkey *ecdsa.PrivateKey) ([]byte, error) {
+	// hash message
+	digest, err := hashMessage(data, hashForCurve(privkey.Curve))
+	if err != nil {
+		return nil, err
+	}
 
-	return signature, nil
+	// sign the hash
+	r, s, err := ecdsa.Sign(rand.Reader, privkey, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	// (r, N-s) is equally valid, so swap in whichever s is smaller
+	n := privkey.Curve.Params().N
+	if s.Cmp(halfOrder(n)) > 0 {
+		s.Sub(n, s)
+	}
+
+	return encodeSignature(r, s, privkey.Curve), nil
 }
 
-// Verify checks a raw ECDSA signature.
+// SignASN1 signs arbitrary data like Sign, but returns the signature as an
+// ASN.1 DER Ecdsa-Sig-Value, the format OpenSSL and X.509 expect.
+func SignASN1(data []byte, privkey *ecdsa.PrivateKey) ([]byte, error) {
+	// hash message
+	digest, err := hashMessage(data, hashForCurve(privkey.Curve))
+	if err != nil {
+		return nil, err
+	}
+
+	return ecdsa.SignASN1(rand.Reader, privkey, digest)
+}
+
+// SignWithSigner signs arbitrary data like Sign, but with an ECDSA key held
+// behind a crypto.Signer, such as a PKCS#11 token, an ssh-agent or a cloud
+// KMS. The signer must return an ASN.1 DER signature, as crypto.Signer
+// implementations for ECDSA do; it is converted to the r||s format Verify
+// accepts.
+func SignWithSigner(data []byte, signer crypto.Signer) ([]byte, error) {
+	pubkey, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("signer does not hold an ECDSA key")
+	}
+
+	// hash message
+	hash := hashForCurve(pubkey.Curve)
+	digest, err := hashMessage(data, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := signer.Sign(rand.Reader, digest, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return SignatureFromASN1(der, pubkey.Curve)
+}
+
+// VerifyWithSigner checks a raw ECDSA signature against the public half of a
+// crypto.Signer, like Verify. It only uses signer.Public, never the key itself.
+// Returns true if it's valid and false if not.
+func VerifyWithSigner(data, signature []byte, signer crypto.Signer) bool {
+	pubkey, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return false
+	}
+	return Verify(data, signature, pubkey)
+}
+
+// Verify checks a raw ECDSA signature made by Sign.
 // Returns true if it's valid and false if not.
 func Verify(data, signature []byte, pubkey *ecdsa.PublicKey) bool {
+	return VerifyErr(data, signature, pubkey) == nil
+}
+
+// VerifyErr checks a raw ECDSA signature made by Sign, like Verify, but
+// returns a *VerifyError describing why it failed, or nil if it's valid.
+func VerifyErr(data, signature []byte, pubkey *ecdsa.PublicKey) error {
+	return verify(data, signature, pubkey, hashForCurve(pubkey.Curve), false)
+}
+
+// VerifyLowS is like VerifyErr, but also rejects signatures whose s value is
+// in the upper half of the curve order with ErrSignatureHighS. For every valid
+// signature (r, s) there is a second valid signature (r, N-s) for the same
+// message; requiring low S leaves only one, for protocols that identify
+// messages by their signature. Pair it with SignLowS.
+func VerifyLowS(data, signature []byte, pubkey *ecdsa.PublicKey) error {
+	return verify(data, signature, pubkey, hashForCurve(pubkey.Curve), true)
+}
+
+// VerifyWithHash checks a raw ECDSA signature made with the given message
+// digest, such as by SignWithHash.
+// Returns true if it's valid and false if not.
+func VerifyWithHash(data, signature []byte, pubkey *ecdsa.PublicKey, hash crypto.Hash) bool {
+	return verify(data, signature, pubkey, hash, false) == nil
+}
+
+// VerifyError describes why a signature failed to verify.
+type VerifyError struct {
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return "verify: " + e.Reason
+}
+
+// The errors returned by VerifyErr, VerifyLowS and VerifyBatch.
+var (
+	ErrSignatureLength   = &VerifyError{"signature has wrong length for curve"}
+	ErrSignatureRange    = &VerifyError{"r or s out of range"}
+	ErrSignatureHighS    = &VerifyError{"s is not in the lower half of the curve order"}
+	ErrSignatureMismatch = &VerifyError{"signature does not match message and key"}
+	ErrHashNotAvailable  = &VerifyError{"hash function is not available"}
+	ErrMissingPublicKey  = &VerifyError{"public key is missing"}
+)
+
+func verify(data, signature []byte, pubkey *ecdsa.PublicKey, hash crypto.Hash, lowS bool) error {
+	r, s, err := parseSignature(signature, pubkey, lowS)
+	if err != nil {
+		return err
+	}
+
 	// hash message
-	digest := sha256.Sum256(data)
+	digest, err := hashMessage(data, hash)
+	if err != nil {
+		return ErrHashNotAvailable
+	}
 
-	curveOrderByteSize := pubkey.Curve.Params().P.BitLen() / 8
+	return verifyDigest(digest, r, s, pubkey)
+}
+
+// parseSignature splits a raw signature into r and s, rejecting malformed
+// ones before any hashing or curve arithmetic is done.
+func parseSignature(signature []byte, pubkey *ecdsa.PublicKey, lowS bool) (r, s *big.Int, err error) {
+	curveOrderByteSize := curveOrderSize(pubkey.Curve)
+	if len(signature) != 2*curveOrderByteSize {
+		return nil, nil, ErrSignatureLength
+	}
 
-	r, s := new(big.Int), new(big.Int)
+	r, s = new(big.Int), new(big.Int)
 	r.SetBytes(signature[:curveOrderByteSize])
 	s.SetBytes(signature[curveOrderByteSize:])
 
-	return ecdsa.Verify(pubkey, digest[:], r, s)
+	n := pubkey.Curve.Params().N
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return nil, nil, ErrSignatureRange
+	}
+	if lowS && s.Cmp(halfOrder(n)) > 0 {
+		return nil, nil, ErrSignatureHighS
+	}
+	return r, s, nil
+}
+
+func verifyDigest(digest []byte, r, s *big.Int, pubkey *ecdsa.PublicKey) error {
+	if !ecdsa.Verify(pubkey, digest, r, s) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+func halfOrder(n *big.Int) *big.Int {
+	return new(big.Int).Rsh(n, 1)
+}
+
+// SignReader signs everything read from r until EOF, like Sign but without
+// holding the data in memory. Signatures from the two are interchangeable.
+func SignReader(r io.Reader, privkey *ecdsa.PrivateKey) ([]byte, error) {
+	signer := NewStreamSigner(privkey)
+	if _, err := io.Copy(signer, r); err != nil {
+		return nil, err
+	}
+	return signer.Sign()
+}
+
+// VerifyReader checks a raw ECDSA signature over everything read from r until
+// EOF. It returns an error from reading r, a *VerifyError like VerifyErr, or
+// nil if the signature is valid.
+func VerifyReader(r io.Reader, signature []byte, pubkey *ecdsa.PublicKey) error {
+	// reject a malformed signature without reading r
+	if _, _, err := parseSignature(signature, pubkey, false); err != nil {
+		return err
+	}
+
+	verifier := NewStreamVerifier(pubkey)
+	if _, err := io.Copy(verifier, r); err != nil {
+		return err
+	}
+	return verifier.Verify(signature)
+}
+
+// StreamSigner signs data written to it in pieces, the way a hash.Hash
+// digests it. It never returns an error from Write.
+type StreamSigner struct {
+	privkey *ecdsa.PrivateKey
+	h       hash.Hash
+}
+
+// NewStreamSigner returns a StreamSigner using the digest Sign would choose
+// for the key's curve.
+func NewStreamSigner(privkey *ecdsa.PrivateKey) *StreamSigner {
+	return &StreamSigner{
+		privkey: privkey,
+		h:       hashForCurve(privkey.Curve).New(),
+	}
+}
+
+// Write adds more data to be signed.
+func (ss *StreamSigner) Write(p []byte) (int, error) {
+	return ss.h.Write(p)
+}
+
+// Reset discards everything written so far.
+func (ss *StreamSigner) Reset() {
+	ss.h.Reset()
+}
+
+// Sign returns a signature over everything written so far, in the same r||s
+// format as Sign. It does not change the underlying state, so more data can
+// be written and signed again.
+func (ss *StreamSigner) Sign() ([]byte, error) {
+	return signDigest(ss.h.Sum(nil), ss.privkey)
+}
+
+// StreamVerifier checks a signature over data written to it in pieces.
+type StreamVerifier struct {
+	pubkey *ecdsa.PublicKey
+	h      hash.Hash
+}
+
+// NewStreamVerifier returns a StreamVerifier using the digest Verify would
+// choose for the key's curve.
+func NewStreamVerifier(pubkey *ecdsa.PublicKey) *StreamVerifier {
+	return &StreamVerifier{
+		pubkey: pubkey,
+		h:      hashForCurve(pubkey.Curve).New(),
+	}
+}
+
+// Write adds more data to be verified.
+func (sv *StreamVerifier) Write(p []byte) (int, error) {
+	return sv.h.Write(p)
+}
+
+// Reset discards everything written so far.
+func (sv *StreamVerifier) Reset() {
+	sv.h.Reset()
+}
+
+// Verify checks a raw ECDSA signature over everything written so far. It
+// returns a *VerifyError like VerifyErr, or nil if the signature is valid.
+func (sv *StreamVerifier) Verify(signature []byte) error {
+	r, s, err := parseSignature(signature, sv.pubkey, false)
+	if err != nil {
+		return err
+	}
+	return verifyDigest(sv.h.Sum(nil), r, s, sv.pubkey)
+}
+
+// SignedItem is one signature for VerifyBatch to check.
+type SignedItem struct {
+	Data      []byte
+	Signature []byte
+	PublicKey *ecdsa.PublicKey
+}
+
+// VerifyBatch checks many raw ECDSA signatures made by Sign, spread across a
+// worker per CPU. The result for each item is at the same index: nil if its
+// signature is valid, otherwise the *VerifyError VerifyErr would return. An
+// item without a usable public key, e.g. because looking it up failed, gets
+// ErrMissingPublicKey rather than bringing down the whole batch.
+func VerifyBatch(items []SignedItem) []error {
+	results := make([]error, len(items))
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(items) {
+					return
+				}
+				item := items[i]
+				if pub := item.PublicKey; pub == nil || pub.Curve == nil || pub.X == nil || pub.Y == nil {
+					results[i] = ErrMissingPublicKey
+					continue
+				}
+				results[i] = VerifyErr(item.Data, item.Signature, item.PublicKey)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// VerifyASN1 checks an ASN.1 DER ECDSA signature, such as one made by
+// SignASN1 or `openssl dgst -sign`. Non-canonical encodings are rejected.
+// Returns true if it's valid and false if not.
+func VerifyASN1(data, signature []byte, pubkey *ecdsa.PublicKey) bool {
+	raw, err := SignatureFromASN1(signature, pubkey.Curve)
+	if err != nil {
+		return false
+	}
+	return Verify(data, raw, pubkey)
+}
+
+// hashForCurve returns the message digest RFC 7518 pairs with the curve.
+func hashForCurve(curve elliptic.Curve) crypto.Hash {
+	switch curve.Params().BitSize {
+	case 384:
+		return crypto.SHA384
+	case 521:
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
+
+func hashMessage(data []byte, hash crypto.Hash) ([]byte, error) {
+	if !hash.Available() {
+		return nil, errors.New("hash function is not available")
+	}
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// curveOrderSize is the length in bytes of each of r and s.
+func curveOrderSize(curve elliptic.Curve) int {
+	return (curve.Params().N.BitLen() + 7) / 8
+}
+
+// encodeSignature encodes the signature {R, S} as fixed-width r||s.
+func encodeSignature(r, s *big.Int, curve elliptic.Curve) []byte {
+	// big.Int.Bytes() will need padding in the case of leading zero bytes
+	curveOrderByteSize := curveOrderSize(curve)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	signature := make([]byte, curveOrderByteSize*2)
+	copy(signature[curveOrderByteSize-len(rBytes):], rBytes)
+	copy(signature[curveOrderByteSize*2-len(sBytes):], sBytes)
+
+	return signature
+}
+
+// NewEd25519SigningKey generates a random Ed25519 private key.
+func NewEd25519SigningKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	return key, err
+}
+
+// SignEd25519 signs arbitrary data using Ed25519. The signature is always 64
+// bytes and is the same every time the same data is signed with the same key.
+func SignEd25519(data []byte, privkey ed25519.PrivateKey) ([]byte, error) {
+	if len(privkey) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid Ed25519 private key")
+	}
+	return ed25519.Sign(privkey, data), nil
+}
+
+// VerifyEd25519 checks an Ed25519 signature.
+// Returns true if it's valid and false if not.
+func VerifyEd25519(data, signature []byte, pubkey ed25519.PublicKey) bool {
+	if len(pubkey) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(pubkey, data, signature)
 }
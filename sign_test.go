@@ -13,11 +13,19 @@ package cryptopasta
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 // https://groups.google.com/d/msg/sci.crypt/OolWgsgQD-8/jHciyWkaL0gJ
@@ -105,3 +113,624 @@ func TestSignWithP384(t *testing.T) {
 		t.Error("signature was good for altered message")
 	}
 }
+
+func TestSignHashSelection(t *testing.T) {
+	hashTests := []struct {
+		curve   elliptic.Curve
+		hash    crypto.Hash
+		sigSize int
+	}{
+		{elliptic.P256(), crypto.SHA256, 64},
+		{elliptic.P384(), crypto.SHA384, 96},
+		{elliptic.P521(), crypto.SHA512, 132},
+	}
+
+	message := []byte("Hello, world!")
+
+	for _, tt := range hashTests {
+		key, err := ecdsa.GenerateKey(tt.curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signature, err := Sign(message, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(signature) != tt.sigSize {
+			t.Errorf("%s: signature is %d bytes, expected %d", tt.curve.Params().Name, len(signature), tt.sigSize)
+		}
+
+		// The signature must be over the curve's own digest.
+		h := tt.hash.New()
+		h.Write(message)
+		r := new(big.Int).SetBytes(signature[:tt.sigSize/2])
+		s := new(big.Int).SetBytes(signature[tt.sigSize/2:])
+		if !ecdsa.Verify(&key.PublicKey, h.Sum(nil), r, s) {
+			t.Errorf("%s: signature was not made with %v", tt.curve.Params().Name, tt.hash)
+		}
+
+		if !Verify(message, signature, &key.PublicKey) {
+			t.Errorf("%s: signature was not correct", tt.curve.Params().Name)
+		}
+	}
+}
+
+func TestSignWithLegacyHash(t *testing.T) {
+	message := []byte("Hello, world!")
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := SignWithHash(message, key, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !VerifyWithHash(message, signature, &key.PublicKey, crypto.SHA256) {
+		t.Error("legacy signature was not correct")
+	}
+
+	if Verify(message, signature, &key.PublicKey) {
+		t.Error("legacy SHA-256 signature was accepted as SHA-384")
+	}
+}
+
+func TestVerifyASN1OpenSSL(t *testing.T) {
+	// Signatures over "Hello, world!" made with the keys in marshal_test.go
+	// using `openssl dgst -sha256 -sign` and `openssl dgst -sha384 -sign`.
+	opensslTests := []struct {
+		publicKey string
+		signature string
+	}{
+		{
+			publicKey: pemECPublicKeyP256,
+			signature: opensslSigP256,
+		},
+		{
+			publicKey: pemECPublicKeyP384,
+			signature: "306402301aa6f3b1f4f493a6c245137df8899f837eab4249c5f5508bd25f02e422c993143dfbfdfeba51a1288b0a71ff340d8875023020fea117107b5b035146bc94d8ada80a66e6d1b369c6765de18cbfd4f651bca79c42397d38eeb6e0bb7997a47dbee232",
+		},
+	}
+
+	for idx, tt := range opensslTests {
+		pubkey, err := DecodePublicKey([]byte(tt.publicKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature, _ := hex.DecodeString(tt.signature)
+
+		if !VerifyASN1([]byte("Hello, world!"), signature, pubkey) {
+			t.Errorf("test %d: openssl signature was not correct", idx)
+		}
+
+		if VerifyASN1([]byte("Hello, world?"), signature, pubkey) {
+			t.Errorf("test %d: signature was good for altered message", idx)
+		}
+	}
+}
+
+func TestSignASN1(t *testing.T) {
+	message := []byte("Hello, world!")
+
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := SignASN1(message, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !VerifyASN1(message, signature, &key.PublicKey) {
+		t.Error("signature was not correct")
+	}
+
+	// The two encodings carry the same signature.
+	raw, err := SignatureFromASN1(signature, key.Curve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(message, raw, &key.PublicKey) {
+		t.Error("converted signature was not correct")
+	}
+}
+
+func TestVerifyErr(t *testing.T) {
+	message := []byte("Hello, world!")
+
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := Sign(message, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := key.Curve.Params().N
+	withS := func(s *big.Int) []byte {
+		sig := append([]byte{}, signature...)
+		for i := 32; i < 64; i++ {
+			sig[i] = 0
+		}
+		sBytes := s.Bytes()
+		copy(sig[64-len(sBytes):], sBytes)
+		return sig
+	}
+
+	verifyTests := []struct {
+		name      string
+		signature []byte
+		expected  error
+	}{
+		{"valid", signature, nil},
+		{"empty", nil, ErrSignatureLength},
+		{"short", signature[:40], ErrSignatureLength},
+		{"trailing bytes", append(append([]byte{}, signature...), 0, 0), ErrSignatureLength},
+		{"zero r", append(make([]byte, 32), signature[32:]...), ErrSignatureRange},
+		{"zero s", withS(new(big.Int)), ErrSignatureRange},
+		{"s equal to N", withS(n), ErrSignatureRange},
+		{"wrong s", withS(big.NewInt(1)), ErrSignatureMismatch},
+	}
+
+	for _, tt := range verifyTests {
+		err := VerifyErr(message, tt.signature, &key.PublicKey)
+		if err != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, err)
+		}
+		if Verify(message, tt.signature, &key.PublicKey) != (tt.expected == nil) {
+			t.Errorf("%s: Verify disagrees with VerifyErr", tt.name)
+		}
+	}
+
+	// The signature is checked before the message is hashed.
+	if err := verify(message, nil, &key.PublicKey, crypto.Hash(0), false); err != ErrSignatureLength {
+		t.Errorf("expected ErrSignatureLength before hashing, got %v", err)
+	}
+	if err := verify(message, signature, &key.PublicKey, crypto.Hash(0), false); err != ErrHashNotAvailable {
+		t.Errorf("expected ErrHashNotAvailable, got %v", err)
+	}
+
+	err = VerifyErr([]byte("Hello, world?"), signature, &key.PublicKey)
+	var verr *VerifyError
+	if !errors.As(err, &verr) || verr != ErrSignatureMismatch {
+		t.Errorf("expected a mismatch *VerifyError, got %v", err)
+	}
+}
+
+func TestSignLowS(t *testing.T) {
+	message := []byte("Hello, world!")
+
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := key.Curve.Params().N
+	half := new(big.Int).Rsh(n, 1)
+
+	for i := 0; i < 16; i++ {
+		signature, err := SignLowS(message, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := new(big.Int).SetBytes(signature[32:])
+		if s.Cmp(half) > 0 {
+			t.Fatal("SignLowS produced a high-S signature")
+		}
+
+		if err := VerifyLowS(message, signature, &key.PublicKey); err != nil {
+			t.Fatal(err)
+		}
+
+		// The high-S twin is a valid ECDSA signature, but not a low-S one.
+		twin := append([]byte{}, signature[:32]...)
+		twin = append(twin, make([]byte, 32)...)
+		highS := new(big.Int).Sub(n, s).Bytes()
+		copy(twin[64-len(highS):], highS)
+
+		if err := VerifyErr(message, twin, &key.PublicKey); err != nil {
+			t.Errorf("high-S signature was rejected by VerifyErr: %v", err)
+		}
+		if err := VerifyLowS(message, twin, &key.PublicKey); err != ErrSignatureHighS {
+			t.Errorf("expected %v, got %v", ErrSignatureHighS, err)
+		}
+	}
+}
+
+// softSigner stands in for a hardware or remote key: it implements
+// crypto.Signer without exposing the private key, and records what it was
+// asked to sign.
+type softSigner struct {
+	key    crypto.Signer
+	err    error
+	digest []byte
+	opts   crypto.SignerOpts
+}
+
+func (s *softSigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s *softSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.digest = append([]byte{}, digest...)
+	s.opts = opts
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.key.Sign(rand, digest, opts)
+}
+
+func TestSignWithSigner(t *testing.T) {
+	message := []byte("Hello, world!")
+
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		name := curve.Params().Name
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer := &softSigner{key: key}
+
+		signature, err := SignWithSigner(message, signer)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if signer.opts.HashFunc() != hashForCurve(curve) {
+			t.Errorf("%s: signer was asked for %v", name, signer.opts.HashFunc())
+		}
+		if len(signature) != 2*curveOrderSize(curve) {
+			t.Errorf("%s: signature is %d bytes", name, len(signature))
+		}
+		if !Verify(message, signature, &key.PublicKey) {
+			t.Errorf("%s: Verify rejected SignWithSigner signature", name)
+		}
+		if !VerifyWithSigner(message, signature, signer) {
+			t.Errorf("%s: VerifyWithSigner rejected signature", name)
+		}
+
+		// Signatures from Sign verify against the signer too.
+		signature, err = Sign(message, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyWithSigner(message, signature, signer) {
+			t.Errorf("%s: VerifyWithSigner rejected Sign signature", name)
+		}
+		if VerifyWithSigner([]byte("Goodbye, world!"), signature, signer) {
+			t.Errorf("%s: signature was good for altered message", name)
+		}
+	}
+}
+
+func TestSignWithSignerErrors(t *testing.T) {
+	message := []byte("Hello, world!")
+
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signErr := errors.New("token removed")
+	if _, err := SignWithSigner(message, &softSigner{key: key, err: signErr}); err != signErr {
+		t.Errorf("expected signer error, got %v", err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edSigner := &softSigner{key: edKey}
+	if _, err := SignWithSigner(message, edSigner); err == nil {
+		t.Error("signed with a non-ECDSA signer")
+	}
+	if edSigner.digest != nil {
+		t.Error("non-ECDSA signer was asked to sign")
+	}
+	if VerifyWithSigner(message, make([]byte, 64), edSigner) {
+		t.Error("verified against a non-ECDSA signer")
+	}
+}
+
+func TestSignReader(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		name := curve.Params().Name
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A signature from either path must verify on the other.
+		streamed, err := SignReader(bytes.NewReader(data), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Verify(data, streamed, &key.PublicKey) {
+			t.Errorf("%s: Verify rejected SignReader signature", name)
+		}
+
+		whole, err := Sign(data, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyReader(bytes.NewReader(data), whole, &key.PublicKey); err != nil {
+			t.Errorf("%s: VerifyReader rejected Sign signature: %v", name, err)
+		}
+
+		altered := append([]byte{}, data...)
+		altered[len(altered)-1] ^= 0xff
+		if err := VerifyReader(bytes.NewReader(altered), whole, &key.PublicKey); err != ErrSignatureMismatch {
+			t.Errorf("%s: expected ErrSignatureMismatch for altered data, got %v", name, err)
+		}
+	}
+}
+
+func TestStreamSigner(t *testing.T) {
+	message := []byte("Hello, world!")
+
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := NewStreamSigner(key)
+	signer.Write([]byte("discarded"))
+	signer.Reset()
+	signer.Write(message[:5])
+	signer.Write(message[5:])
+	signature, err := signer.Sign()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(message, signature, &key.PublicKey) {
+		t.Error("signature over pieces was not correct")
+	}
+
+	verifier := NewStreamVerifier(&key.PublicKey)
+	verifier.Write(message[:7])
+	verifier.Write(message[7:])
+	if err := verifier.Verify(signature); err != nil {
+		t.Errorf("StreamVerifier rejected signature: %v", err)
+	}
+	if err := verifier.Verify(signature[:len(signature)-1]); err != ErrSignatureLength {
+		t.Errorf("expected ErrSignatureLength, got %v", err)
+	}
+}
+
+func TestSignReaderError(t *testing.T) {
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readErr := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(readErr))
+
+	if _, err := SignReader(r, key); err != readErr {
+		t.Errorf("SignReader: expected read error, got %v", err)
+	}
+
+	signature, err := Sign([]byte("partial"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(readErr))
+	if err := VerifyReader(r, signature, &key.PublicKey); err != readErr {
+		t.Errorf("VerifyReader: expected read error, got %v", err)
+	}
+
+	// A malformed signature is rejected before anything is read.
+	r = iotest.ErrReader(readErr)
+	if err := VerifyReader(r, make([]byte, 64), &key.PublicKey); err != ErrSignatureRange {
+		t.Errorf("VerifyReader: expected ErrSignatureRange, got %v", err)
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	var items []SignedItem
+	for i, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 10; j++ {
+			data := []byte{byte(i), byte(j)}
+			signature, err := Sign(data, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, SignedItem{data, signature, &key.PublicKey})
+		}
+	}
+
+	items[3].Data = []byte("altered")
+	items[9].PublicKey = &ecdsa.PublicKey{Curve: elliptic.P256()}
+	items[15].Signature = items[15].Signature[1:]
+	items[27].PublicKey = items[0].PublicKey
+	items[28].PublicKey = nil
+	items[29].PublicKey = &ecdsa.PublicKey{}
+
+	expected := make([]error, len(items))
+	expected[3] = ErrSignatureMismatch
+	expected[9] = ErrMissingPublicKey
+	expected[15] = ErrSignatureLength
+	expected[27] = ErrSignatureLength
+	expected[28] = ErrMissingPublicKey
+	expected[29] = ErrMissingPublicKey
+
+	results := VerifyBatch(items)
+	if len(results) != len(items) {
+		t.Fatalf("got %d results for %d items", len(results), len(items))
+	}
+	for i, err := range results {
+		if err != expected[i] {
+			t.Errorf("item %d: expected %v, got %v", i, expected[i], err)
+		}
+	}
+
+	if results := VerifyBatch(nil); len(results) != 0 {
+		t.Errorf("got %d results for no items", len(results))
+	}
+}
+
+func benchmarkSignedItems(b *testing.B, n int) []SignedItem {
+	key, err := NewSigningKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	items := make([]SignedItem, n)
+	for i := range items {
+		data := make([]byte, 256)
+		rand.Read(data)
+		signature, err := Sign(data, key)
+		if err != nil {
+			b.Fatal(err)
+		}
+		items[i] = SignedItem{data, signature, &key.PublicKey}
+	}
+	return items
+}
+
+func BenchmarkVerifyLoop(b *testing.B) {
+	items := benchmarkSignedItems(b, 1000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, item := range items {
+			if !Verify(item.Data, item.Signature, item.PublicKey) {
+				b.Fatal("signature was not correct")
+			}
+		}
+	}
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	items := benchmarkSignedItems(b, 1000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, err := range VerifyBatch(items) {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func FuzzVerify(f *testing.F) {
+	key, err := DecodePrivateKey([]byte(pemECPrivateKeyP256))
+	if err != nil {
+		f.Fatal(err)
+	}
+	message := []byte("Hello, world!")
+
+	signature, err := Sign(message, key)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(signature)
+	f.Add(signature[:31])
+	f.Add(append(signature, 0))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, signature []byte) {
+		err := VerifyErr(message, signature, &key.PublicKey)
+		if err == nil && len(signature) != 64 {
+			t.Errorf("accepted a %d-byte signature", len(signature))
+		}
+		if err != nil {
+			if _, ok := err.(*VerifyError); !ok {
+				t.Errorf("unexpected error type %T", err)
+			}
+		}
+		if Verify(message, signature, &key.PublicKey) != (err == nil) {
+			t.Error("Verify disagrees with VerifyErr")
+		}
+	})
+}
+
+// Test 1 from https://tools.ietf.org/html/rfc8032#section-7.1
+var ed25519Tests = []struct {
+	seed      string
+	publicKey string
+	message   string
+	signature string
+}{
+	{
+		seed:      "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+		publicKey: "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+		message:   "",
+		signature: "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b",
+	},
+}
+
+func TestSignEd25519Vectors(t *testing.T) {
+	for idx, tt := range ed25519Tests {
+		seed, _ := hex.DecodeString(tt.seed)
+		pubBytes, _ := hex.DecodeString(tt.publicKey)
+		message, _ := hex.DecodeString(tt.message)
+		expected, _ := hex.DecodeString(tt.signature)
+
+		key := ed25519.NewKeyFromSeed(seed)
+		signature, err := SignEd25519(message, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(signature, expected) {
+			t.Errorf("test %d generated unexpected signature", idx)
+		}
+
+		if !VerifyEd25519(message, signature, ed25519.PublicKey(pubBytes)) {
+			t.Errorf("test %d signature was not correct", idx)
+		}
+	}
+}
+
+func TestSignEd25519(t *testing.T) {
+	message := []byte("Hello, world!")
+
+	key, err := NewEd25519SigningKey()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	signature, err := SignEd25519(message, key)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	pubkey := key.Public().(ed25519.PublicKey)
+	if !VerifyEd25519(message, signature, pubkey) {
+		t.Error("signature was not correct")
+		return
+	}
+
+	message[0] ^= 0xff
+	if VerifyEd25519(message, signature, pubkey) {
+		t.Error("signature was good for altered message")
+	}
+
+	if VerifyEd25519(message, signature, pubkey[:16]) {
+		t.Error("signature was good for truncated public key")
+	}
+
+	if _, err := SignEd25519(message, key[:16]); err == nil {
+		t.Error("signed with truncated private key")
+	}
+}
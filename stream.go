@@ -0,0 +1,263 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides streaming authenticated encryption for data that is too large to
+// hold in memory.
+//
+// The stream is split into 64KiB segments, each sealed with 256-bit AES-GCM.
+// A fresh per-stream key is derived from the caller's key and a random salt
+// that is written at the start of the stream. Each segment's nonce is a
+// big-endian segment counter followed by a flag byte that is set only on the
+// final segment. This is the STREAM construction of Hoang, Reyhanitabar,
+// Rogaway and Vizár: reordering, duplicating or dropping segments changes the
+// nonce a segment is opened with, and truncating the stream leaves a final
+// segment that was not sealed as final, so all of these fail to decrypt.
+package cryptopasta
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	streamSaltSize    = 16
+	streamSegmentSize = 64 * 1024
+)
+
+// NewEncryptWriter returns a WriteCloser that encrypts everything written to
+// it using the segmented AES-GCM stream format and writes the result to w. The
+// caller must call Close to write the final segment; Close does not close w.
+func NewEncryptWriter(w io.Writer, key *[32]byte) (io.WriteCloser, error) {
+	salt := make([]byte, streamSaltSize)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newStreamCipher(salt, key)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:   w,
+		gcm: gcm,
+		buf: make([]byte, 0, streamSegmentSize),
+		out: make([]byte, 0, streamSegmentSize+gcm.Overhead()),
+	}, nil
+}
+
+// NewDecryptReader returns a Reader that decrypts a stream produced by
+// NewEncryptWriter. Plaintext is returned one segment at a time as each
+// segment is authenticated. A stream that was truncated, reordered or altered
+// results in an error, but only once the damaged segment is reached, so
+// callers must not act on the data until Read has returned io.EOF.
+func NewDecryptReader(r io.Reader, key *[32]byte) (io.Reader, error) {
+	salt := make([]byte, streamSaltSize)
+	_, err := io.ReadFull(r, salt)
+	if err != nil {
+		return nil, errors.New("malformed ciphertext")
+	}
+
+	gcm, err := newStreamCipher(salt, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		r:     r,
+		gcm:   gcm,
+		buf:   make([]byte, streamSegmentSize+gcm.Overhead()+1),
+		plain: make([]byte, 0, streamSegmentSize),
+	}, nil
+}
+
+// newStreamCipher derives the per-stream key from the caller's key and the
+// stream salt, so that segment nonces only need to be unique within a stream.
+func newStreamCipher(salt []byte, key *[32]byte) (cipher.AEAD, error) {
+	h := hmac.New(sha512.New512_256, key[:])
+	h.Write([]byte("cryptopasta stream segment key"))
+	h.Write(salt)
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// streamNonce builds the segment nonce 0...0|counter|last.
+func streamNonce(nonce []byte, counter uint32, last bool) []byte {
+	for i := range nonce {
+		nonce[i] = 0
+	}
+	binary.BigEndian.PutUint32(nonce[len(nonce)-5:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+type encryptWriter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	buf     []byte // pending plaintext for the current segment
+	out     []byte // sealed segment
+	counter uint32
+	closed  bool
+	err     error
+}
+
+func (ew *encryptWriter) Write(p []byte) (n int, err error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	if ew.closed {
+		return 0, errors.New("write to closed stream")
+	}
+
+	for len(p) > 0 {
+		// A full segment is only sealed once more data arrives, so that the
+		// final segment can always be marked as such by Close.
+		if len(ew.buf) == streamSegmentSize {
+			if err := ew.seal(false); err != nil {
+				return n, err
+			}
+		}
+
+		m := copy(ew.buf[len(ew.buf):streamSegmentSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+m]
+		p = p[m:]
+		n += m
+	}
+
+	return n, nil
+}
+
+// Close seals and writes the final segment.
+func (ew *encryptWriter) Close() error {
+	if ew.closed {
+		return ew.err
+	}
+	ew.closed = true
+	if ew.err != nil {
+		return ew.err
+	}
+	return ew.seal(true)
+}
+
+func (ew *encryptWriter) seal(last bool) error {
+	if !last && ew.counter == math.MaxUint32 {
+		ew.err = errors.New("stream too long")
+		return ew.err
+	}
+
+	nonce := streamNonce(make([]byte, ew.gcm.NonceSize()), ew.counter, last)
+	ew.out = ew.gcm.Seal(ew.out[:0], nonce, ew.buf, nil)
+	if _, err := ew.w.Write(ew.out); err != nil {
+		ew.err = err
+		return err
+	}
+
+	ew.buf = ew.buf[:0]
+	ew.counter++
+	return nil
+}
+
+type decryptReader struct {
+	r       io.Reader
+	gcm     cipher.AEAD
+	buf     []byte // one sealed segment plus one byte of lookahead
+	carry   bool   // whether buf[0] holds lookahead from the previous read
+	plain   []byte // decrypted segment
+	out     []byte // decrypted plaintext not yet returned
+	counter uint32
+	done    bool
+	err     error
+}
+
+func (dr *decryptReader) Read(p []byte) (n int, err error) {
+	for len(dr.out) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		if dr.done {
+			return 0, io.EOF
+		}
+		dr.err = dr.open()
+	}
+
+	n = copy(p, dr.out)
+	dr.out = dr.out[n:]
+	return n, nil
+}
+
+// open reads and authenticates the next segment. A segment is the final one
+// if the stream ends before the byte following it.
+func (dr *decryptReader) open() error {
+	segmentSize := len(dr.buf) - 1
+
+	start := 0
+	if dr.carry {
+		start = 1
+	}
+	n, err := io.ReadFull(dr.r, dr.buf[start:])
+	n += start
+
+	last := false
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+
+	if last && n < dr.gcm.Overhead() {
+		return errors.New("malformed ciphertext")
+	}
+	if !last && dr.counter == math.MaxUint32 {
+		return errors.New("stream too long")
+	}
+
+	segment := dr.buf[:n]
+	if !last {
+		segment = dr.buf[:segmentSize]
+	}
+
+	nonce := streamNonce(make([]byte, dr.gcm.NonceSize()), dr.counter, last)
+	dr.out, err = dr.gcm.Open(dr.plain[:0], nonce, segment, nil)
+	if err != nil {
+		return err
+	}
+
+	if last {
+		dr.done = true
+		return nil
+	}
+
+	dr.buf[0] = dr.buf[segmentSize]
+	dr.carry = true
+	dr.counter++
+	return nil
+}
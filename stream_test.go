@@ -0,0 +1,170 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func encryptStream(t *testing.T, plaintext []byte, key *[32]byte) []byte {
+	var buf bytes.Buffer
+	w, err := NewEncryptWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write in odd-sized pieces to exercise segment boundaries.
+	for len(plaintext) > 0 {
+		n := 1000
+		if n > len(plaintext) {
+			n = len(plaintext)
+		}
+		if _, err := w.Write(plaintext[:n]); err != nil {
+			t.Fatal(err)
+		}
+		plaintext = plaintext[n:]
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptStream(ciphertext []byte, key *[32]byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func TestEncryptDecryptStream(t *testing.T) {
+	key := NewEncryptionKey()
+
+	sizes := []int{
+		0,
+		1,
+		streamSegmentSize - 1,
+		streamSegmentSize,
+		streamSegmentSize + 1,
+		3*streamSegmentSize + 5,
+	}
+
+	for _, size := range sizes {
+		plaintext := make([]byte, size)
+		_, err := io.ReadFull(rand.Reader, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ciphertext := encryptStream(t, plaintext, key)
+		decrypted, err := decryptStream(ciphertext, key)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("size %d: plaintexts don't match", size)
+		}
+
+		_, err = decryptStream(ciphertext, NewEncryptionKey())
+		if err == nil {
+			t.Errorf("size %d: decrypted with the wrong key", size)
+		}
+	}
+}
+
+func TestStreamBigFile(t *testing.T) {
+	key := NewEncryptionKey()
+
+	data, err := ioutil.ReadFile("testdata/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := decryptStream(encryptStream(t, data, key), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decrypted, data) {
+		t.Error("plaintexts don't match")
+	}
+}
+
+func TestStreamTampering(t *testing.T) {
+	key := NewEncryptionKey()
+
+	plaintext := make([]byte, 3*streamSegmentSize+5)
+	ciphertext := encryptStream(t, plaintext, key)
+
+	sealedSize := streamSegmentSize + 16
+	segment := func(i int) []byte {
+		start := streamSaltSize + i*sealedSize
+		end := start + sealedSize
+		if end > len(ciphertext) {
+			end = len(ciphertext)
+		}
+		return ciphertext[start:end]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	header := ciphertext[:streamSaltSize]
+
+	tamperTests := []struct {
+		name       string
+		ciphertext []byte
+	}{
+		{"empty", nil},
+		{"header only", join(header)},
+		{"truncated at segment boundary", join(header, segment(0), segment(1))},
+		{"truncated mid-segment", ciphertext[:len(ciphertext)-3]},
+		{"final segment dropped", join(header, segment(0), segment(1), segment(2))},
+		{"segments reordered", join(header, segment(1), segment(0), segment(2), segment(3))},
+		{"segment duplicated", join(header, segment(0), segment(0), segment(1), segment(2), segment(3))},
+		{"trailing data", join(ciphertext, []byte{0})},
+		{"flipped bit", func() []byte {
+			c := join(ciphertext)
+			c[streamSaltSize+sealedSize+7] ^= 0x01
+			return c
+		}()},
+	}
+
+	for _, tt := range tamperTests {
+		_, err := decryptStream(tt.ciphertext, key)
+		if err == nil {
+			t.Errorf("%s: decrypted without error", tt.name)
+		}
+	}
+}
+
+func BenchmarkStream(b *testing.B) {
+	key := NewEncryptionKey()
+
+	data, err := ioutil.ReadFile("testdata/big")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		w, _ := NewEncryptWriter(ioutil.Discard, key)
+		w.Write(data)
+		w.Close()
+	}
+}
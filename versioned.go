@@ -0,0 +1,132 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+// Provides a self-describing ciphertext format so that stored data can be
+// migrated to new algorithms and keys.
+//
+// A versioned ciphertext takes the form header|nonce|ciphertext|tag. The first
+// header byte identifies the format version and algorithm, with its high bit
+// set if a key ID follows as a length byte and up to 255 bytes of ID. The
+// header is authenticated along with the ciphertext, so it can't be altered
+// to make the data decrypt under a different algorithm or key.
+package cryptopasta
+
+import (
+	"errors"
+)
+
+// Algorithm identifies the cipher used for a versioned ciphertext.
+type Algorithm byte
+
+const (
+	// AES256GCM is 256-bit AES-GCM with a random 96-bit nonce, as in Encrypt.
+	AES256GCM Algorithm = 0x01
+	// XChaCha20Poly1305 is XChaCha20-Poly1305 with a random 192-bit nonce,
+	// as in EncryptXChaCha.
+	XChaCha20Poly1305 Algorithm = 0x02
+)
+
+const versionedKeyIDFlag = 0x80
+
+// EncryptVersioned encrypts data using the given algorithm and prefixes it
+// with a header recording the algorithm and, if it's not empty, keyID. The key
+// ID is stored in the clear; it names a key and must not be secret.
+func EncryptVersioned(plaintext []byte, alg Algorithm, keyID string, key *[32]byte) ([]byte, error) {
+	if len(keyID) > 255 {
+		return nil, errors.New("key ID too long")
+	}
+
+	header := []byte{byte(alg)}
+	if keyID != "" {
+		header[0] |= versionedKeyIDFlag
+		header = append(header, byte(len(keyID)))
+		header = append(header, keyID...)
+	}
+
+	var body []byte
+	var err error
+	switch alg {
+	case AES256GCM:
+		body, err = EncryptWithAD(plaintext, header, key)
+	case XChaCha20Poly1305:
+		body, err = encryptXChaCha(plaintext, header, key)
+	default:
+		return nil, errors.New("unknown algorithm")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(header, body...), nil
+}
+
+// DecryptVersioned decrypts data produced by EncryptVersioned, using whichever
+// algorithm its header names. It also accepts legacy output from Encrypt,
+// which has no header.
+func DecryptVersioned(ciphertext []byte, key *[32]byte) ([]byte, error) {
+	alg, _, header, err := parseVersionedHeader(ciphertext)
+	if err == nil {
+		var plaintext []byte
+		body := ciphertext[len(header):]
+		switch alg {
+		case AES256GCM:
+			plaintext, err = DecryptWithAD(body, header, key)
+		case XChaCha20Poly1305:
+			plaintext, err = decryptXChaCha(body, header, key)
+		}
+		if err == nil {
+			return plaintext, nil
+		}
+	}
+
+	// Legacy ciphertexts begin with a random nonce, so about one in every
+	// sixty-four will also look like it has a valid header. Since both paths
+	// are authenticated, trying the other one is safe.
+	return Decrypt(ciphertext, key)
+}
+
+// VersionedKeyID returns the key ID recorded in a versioned ciphertext, or
+// false if it has none. The ID is not authenticated until the ciphertext has
+// been decrypted, so it should only be used to choose a key.
+func VersionedKeyID(ciphertext []byte) (string, bool) {
+	_, keyID, _, err := parseVersionedHeader(ciphertext)
+	if err != nil || keyID == "" {
+		return "", false
+	}
+	return keyID, true
+}
+
+// parseVersionedHeader splits a versioned ciphertext header into its parts
+// and returns the raw header bytes.
+func parseVersionedHeader(ciphertext []byte) (alg Algorithm, keyID string, header []byte, err error) {
+	if len(ciphertext) < 1 {
+		return 0, "", nil, errors.New("malformed ciphertext")
+	}
+
+	alg = Algorithm(ciphertext[0] &^ versionedKeyIDFlag)
+	if alg != AES256GCM && alg != XChaCha20Poly1305 {
+		return 0, "", nil, errors.New("unknown algorithm")
+	}
+
+	if ciphertext[0]&versionedKeyIDFlag == 0 {
+		return alg, "", ciphertext[:1], nil
+	}
+
+	if len(ciphertext) < 2 {
+		return 0, "", nil, errors.New("malformed ciphertext")
+	}
+	idLen := int(ciphertext[1])
+	if idLen == 0 || len(ciphertext) < 2+idLen {
+		return 0, "", nil, errors.New("malformed ciphertext")
+	}
+
+	return alg, string(ciphertext[2 : 2+idLen]), ciphertext[:2+idLen], nil
+}
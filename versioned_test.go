@@ -0,0 +1,133 @@
+// cryptopasta - basic cryptography examples
+//
+// Written in 2016 by George Tankersley <george.tankersley@gmail.com>
+//
+// To the extent possible under law, the author(s) have dedicated all copyright
+// and related and neighboring rights to this software to the public domain
+// worldwide. This software is distributed without any warranty.
+//
+// You should have received a copy of the CC0 Public Domain Dedication along
+// with this software. If not, see // <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package cryptopasta
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecryptVersioned(t *testing.T) {
+	key := NewEncryptionKey()
+	message := []byte("Hello, world!")
+
+	versionedTests := []struct {
+		alg   Algorithm
+		keyID string
+	}{
+		{AES256GCM, ""},
+		{AES256GCM, "2016-primary"},
+		{XChaCha20Poly1305, ""},
+		{XChaCha20Poly1305, "2016-primary"},
+	}
+
+	for _, tt := range versionedTests {
+		ciphertext, err := EncryptVersioned(message, tt.alg, tt.keyID, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if Algorithm(ciphertext[0]&^versionedKeyIDFlag) != tt.alg {
+			t.Errorf("header names algorithm %x, expected %x", ciphertext[0], tt.alg)
+		}
+
+		keyID, ok := VersionedKeyID(ciphertext)
+		if keyID != tt.keyID || ok != (tt.keyID != "") {
+			t.Errorf("got key ID %q, expected %q", keyID, tt.keyID)
+		}
+
+		plaintext, err := DecryptVersioned(ciphertext, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(plaintext, message) {
+			t.Errorf("plaintexts don't match")
+		}
+
+		_, err = DecryptVersioned(ciphertext, NewEncryptionKey())
+		if err == nil {
+			t.Errorf("decrypted with the wrong key")
+		}
+	}
+}
+
+func TestVersionedHeaderIsAuthenticated(t *testing.T) {
+	key := NewEncryptionKey()
+
+	ciphertext, err := EncryptVersioned([]byte("Hello, world!"), AES256GCM, "a", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Relabel the key ID.
+	relabeled := append([]byte{}, ciphertext...)
+	relabeled[2] = 'b'
+	if _, err := DecryptVersioned(relabeled, key); err == nil {
+		t.Error("decrypted with an altered key ID")
+	}
+
+	// Strip the key ID entirely.
+	stripped := append([]byte{byte(AES256GCM)}, ciphertext[3:]...)
+	if _, err := DecryptVersioned(stripped, key); err == nil {
+		t.Error("decrypted with the key ID removed")
+	}
+}
+
+func TestDecryptVersionedLegacy(t *testing.T) {
+	key := NewEncryptionKey()
+	message := []byte("Hello, world!")
+
+	// Keep going until we've seen a legacy ciphertext whose random nonce
+	// happens to look like a versioned header.
+	sawHeaderLike := false
+	for i := 0; i < 2000 && !sawHeaderLike; i++ {
+		ciphertext, err := Encrypt(message, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, _, err := parseVersionedHeader(ciphertext); err == nil {
+			sawHeaderLike = true
+		}
+
+		plaintext, err := DecryptVersioned(ciphertext, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(plaintext, message) {
+			t.Fatal("plaintexts don't match")
+		}
+	}
+
+	if !sawHeaderLike {
+		t.Error("never produced a legacy ciphertext with a header-like prefix")
+	}
+}
+
+func TestEncryptVersionedErrors(t *testing.T) {
+	key := NewEncryptionKey()
+
+	if _, err := EncryptVersioned(nil, Algorithm(0x7f), "", key); err == nil {
+		t.Error("encrypted with an unknown algorithm")
+	}
+
+	if _, err := EncryptVersioned(nil, AES256GCM, strings.Repeat("k", 256), key); err == nil {
+		t.Error("encrypted with an overlong key ID")
+	}
+
+	if _, err := DecryptVersioned(nil, key); err == nil {
+		t.Error("decrypted an empty ciphertext")
+	}
+}